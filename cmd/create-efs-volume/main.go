@@ -4,7 +4,9 @@ import (
 	"context"
 	"os"
 
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"k8s.io/component-base/cli"
 
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
@@ -14,7 +16,9 @@ import (
 )
 
 var (
-	useLocalAWSCredentials bool
+	options          efscreate.Options
+	destroyOptions   efscreate.DestroyOptions
+	accessPointSpecs []string
 )
 
 func main() {
@@ -33,25 +37,79 @@ func NewOperatorCommand() *cobra.Command {
 		},
 	}
 
+	ctrlCmd := newCLICommand(runOperatorWithCredentialsConfig)
+	ctrlCmd.Use = "start"
+	ctrlCmd.Short = "Create EFS volume"
+	flags := ctrlCmd.Flags()
+	addCredentialsFlags(flags, &options.UseLocalAWSCredentials, &options.Credentials)
+	addCrossAccountFlags(flags, &options.CrossAccount)
+	flags.BoolVar(&options.KeepOnFailure, "keep-on-failure", false, "Keep AWS resources created by a failed run instead of rolling them back.")
+	flags.StringVar(&options.ReportFile, "output-report", "", "Write a report of the created AWS resources to this file. Use .json extension for JSON, .yaml or .yml for YAML.")
+	flags.StringVar(&options.FileSystem.PerformanceMode, "performance-mode", awsefs.PerformanceModeGeneralPurpose, "Performance mode of the filesystem: generalPurpose or maxIO.")
+	flags.StringVar(&options.FileSystem.ThroughputMode, "throughput-mode", "", "Throughput mode of the filesystem: bursting, provisioned or elastic. AWS default is used when empty.")
+	flags.Float64Var(&options.FileSystem.ProvisionedThroughputInMibps, "provisioned-throughput", 0, "Provisioned throughput of the filesystem in MiB/s. Required with --throughput-mode=provisioned.")
+	flags.StringVar(&options.FileSystem.KMSKeyID, "kms-key-id", "", "ID or ARN of a customer managed KMS key to encrypt the filesystem. AWS managed key is used when empty.")
+	flags.StringVar(&options.FileSystem.TransitionToIA, "transition-to-ia", "", "Lifecycle policy to move files to Infrequent Access storage class, e.g. AFTER_30_DAYS.")
+	flags.StringVar(&options.FileSystem.TransitionToArchive, "transition-to-archive", "", "Lifecycle policy to move files to Archive storage class, e.g. AFTER_90_DAYS.")
+	flags.BoolVar(&options.FileSystem.Backup, "enable-backups", false, "Enable automatic backups of the filesystem.")
+	flags.StringVar(&options.IngressSource, "ingress-source", efscreate.IngressSourceVPCCIDR, "Allow NFS traffic from all CIDR blocks of the cluster VPC (vpc-cidr) or only from security groups of the cluster nodes (node-security-groups).")
+	flags.StringVar(&options.IPAddressType, "ip-address-type", efscreate.IPAddressTypeAuto, "IP address type of mount targets: IPV4_ONLY, DUAL_STACK or IPV6_ONLY. With auto, it's detected from CIDR blocks of each subnet.")
+	flags.StringSliceVar(&options.SubnetIDs, "subnet-ids", nil, "Comma separated list of subnets to create mount targets in, one per availability zone. By default, subnets of the cluster nodes and private subnets tagged for the cluster are used.")
+	flags.StringArrayVar(&accessPointSpecs, "access-point", nil, "Create an access point in form <uid>:<gid>:<path>[:<permissions>], e.g. 1000:1000:/data:750. Can be repeated.")
+	flags.StringVar(&options.StaticPVFile, "static-pv-location", "", "Write static PersistentVolume and PersistentVolumeClaim manifests for the filesystem or its access points to this file.")
+	flags.StringVar(&options.OneZone, "one-zone", "", "Create a One Zone filesystem in the given availability zone instead of a Regional one.")
+	flags.StringVar(&options.CrossAccount.VPCID, "cross-account-vpc-id", "", "VPC in the filesystem account where mount targets are created. It must be peered with or shared to the cluster VPC.")
+	flags.StringVar(&options.CrossAccount.DriverRoleARN, "cross-account-driver-role-arn", "", "IAM role of the CSI driver that the filesystem policy allows to mount the filesystem.")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "Only discover the cluster and print the AWS resources that would be created or reused, without changing anything.")
+	cmd.AddCommand(ctrlCmd)
+
+	destroyCmd := newCLICommand(runDestroyWithCredentialsConfig)
+	destroyCmd.Use = "destroy"
+	destroyCmd.Short = "Delete EFS volume and all AWS resources created by the start command"
+	addCredentialsFlags(destroyCmd.Flags(), &destroyOptions.UseLocalAWSCredentials, &destroyOptions.Credentials)
+	addCrossAccountFlags(destroyCmd.Flags(), &destroyOptions.CrossAccount)
+	cmd.AddCommand(destroyCmd)
+
+	return cmd
+}
+
+func addCredentialsFlags(flags *pflag.FlagSet, useLocalAWSCredentials *bool, credentials *efscreate.CredentialsOptions) {
+	flags.BoolVar(useLocalAWSCredentials, "local-aws-creds", false, "Use local AWS credentials instead of credentials loaded from the OCP cluster.")
+	flags.StringVar(&credentials.SecretNamespace, "aws-creds-secret-namespace", "", "Namespace of the secret with AWS credentials. Defaults to the operator namespace.")
+	flags.StringVar(&credentials.SecretName, "aws-creds-secret-name", "", "Name of the secret with AWS credentials. By default, kube-system/aws-creds and aws-efs-cloud-credentials in the operator namespace are tried.")
+	flags.StringVar(&credentials.Profile, "aws-profile", "", "Use this profile from local AWS config. Implies --local-aws-creds.")
+	flags.StringVar(&credentials.AssumeRoleARN, "assume-role-arn", "", "Assume this IAM role using the credentials loaded from the other sources.")
+	flags.StringVar(&credentials.ExternalID, "external-id", "", "External ID used to assume the role from --assume-role-arn.")
+}
+
+func addCrossAccountFlags(flags *pflag.FlagSet, crossAccount *efscreate.CrossAccountOptions) {
+	flags.StringVar(&crossAccount.RoleARN, "cross-account-role-arn", "", "Create the filesystem in another AWS account by assuming this IAM role in it.")
+	flags.StringVar(&crossAccount.ExternalID, "cross-account-external-id", "", "External ID used to assume the role from --cross-account-role-arn.")
+}
+
+func newCLICommand(startFunc controllercmd.StartFunc) *cobra.Command {
 	ctrlCmdConfig := controllercmd.NewControllerCommandConfig(
 		"create-efs-volume",
 		version.Get(),
-		runOperatorWithCredentialsConfig,
+		startFunc,
 	)
 	// we don't need leader election and metrics for CLI commands
 	ctrlCmdConfig.DisableLeaderElection = true
 	ctrlCmdConfig.DisableServing = true
-
-	ctrlCmd := ctrlCmdConfig.NewCommand()
-	ctrlCmd.Use = "start"
-	ctrlCmd.Short = "Create EFS volume"
-	flags := ctrlCmd.Flags()
-	flags.BoolVar(&useLocalAWSCredentials, "local-aws-creds", false, "Use local AWS credentials instead of credentials loaded from the OCP cluster.")
-	cmd.AddCommand(ctrlCmd)
-
-	return cmd
+	return ctrlCmdConfig.NewCommand()
 }
 
 func runOperatorWithCredentialsConfig(ctx context.Context, controllerConfig *controllercmd.ControllerContext) error {
-	return efscreate.RunOperator(ctx, controllerConfig, useLocalAWSCredentials)
+	for _, spec := range accessPointSpecs {
+		ap, err := efscreate.ParseAccessPoint(spec)
+		if err != nil {
+			return err
+		}
+		options.AccessPoints = append(options.AccessPoints, ap)
+	}
+	return efscreate.RunOperator(ctx, controllerConfig, options)
+}
+
+func runDestroyWithCredentialsConfig(ctx context.Context, controllerConfig *controllercmd.ControllerContext) error {
+	return efscreate.RunDestroy(ctx, controllerConfig, destroyOptions)
 }
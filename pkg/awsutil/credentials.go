@@ -0,0 +1,87 @@
+package awsutil
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// CredentialsFromSecret supports both Secrets with aws_access_key_id and
+// aws_secret_access_key keys and Secrets with a credentials file created by
+// cloud-credential-operator, which may use role_arn and web_identity_token_file.
+// The role is assumed with the given session name. When the token file is not
+// available locally, the token is fetched with tokenFetcher. A nil
+// tokenFetcher makes a missing token file an error.
+func CredentialsFromSecret(awsCreds *corev1.Secret, region, sessionName string, tokenFetcher stscreds.TokenFetcher) (*credentials.Credentials, error) {
+	id, idFound := awsCreds.Data["aws_access_key_id"]
+	key, keyFound := awsCreds.Data["aws_secret_access_key"]
+	if idFound && keyFound {
+		klog.V(2).Infof("Using AWS credentials from the cluster, got key id: %s", id)
+		return credentials.NewStaticCredentials(string(id), string(key), ""), nil
+	}
+
+	file, found := awsCreds.Data["credentials"]
+	if !found {
+		return nil, fmt.Errorf("neither aws_access_key_id and aws_secret_access_key nor credentials found")
+	}
+	values := parseCredentialsFile(file)
+	if values["aws_access_key_id"] != "" && values["aws_secret_access_key"] != "" {
+		klog.V(2).Infof("Using AWS credentials from the cluster, got key id: %s", values["aws_access_key_id"])
+		return credentials.NewStaticCredentials(values["aws_access_key_id"], values["aws_secret_access_key"], values["aws_session_token"]), nil
+	}
+
+	roleARN := values["role_arn"]
+	if roleARN == "" {
+		return nil, fmt.Errorf("credentials contain neither static keys nor role_arn")
+	}
+	tokenFile := values["web_identity_token_file"]
+	if _, err := os.Stat(tokenFile); tokenFile != "" && err == nil {
+		tokenFetcher = stscreds.FetchTokenPath(tokenFile)
+	} else if tokenFetcher == nil {
+		return nil, fmt.Errorf("web identity token file %q of role %s not found", tokenFile, roleARN)
+	}
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, err
+	}
+	klog.V(2).Infof("Using AWS role %s from the cluster", roleARN)
+	provider := stscreds.NewWebIdentityRoleProviderWithOptions(sts.New(sess), roleARN, sessionName, tokenFetcher)
+	return credentials.NewCredentials(provider), nil
+}
+
+// parseCredentialsFile returns keys of the default profile in an AWS
+// credentials file.
+func parseCredentialsFile(content []byte) map[string]string {
+	values := map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != "default" {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return values
+}
@@ -0,0 +1,131 @@
+package awsutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type fakeTokenFetcher struct{}
+
+func (fakeTokenFetcher) FetchToken(credentials.Context) ([]byte, error) {
+	return []byte("token"), nil
+}
+
+func TestCredentialsFromSecret(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("token"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	roleFile := func(tokenFile string) []byte {
+		return []byte("[default]\nrole_arn = arn:aws:iam::123456789012:role/efs\nweb_identity_token_file = " + tokenFile + "\n")
+	}
+
+	tests := []struct {
+		name         string
+		data         map[string][]byte
+		tokenFetcher stscreds.TokenFetcher
+		// Static credentials are resolved without AWS, roles are not
+		expectedKeyID string
+		expectedErr   string
+	}{
+		{
+			name: "static keys",
+			data: map[string][]byte{
+				"aws_access_key_id":     []byte("AKIDSECRET"),
+				"aws_secret_access_key": []byte("secret"),
+			},
+			expectedKeyID: "AKIDSECRET",
+		},
+		{
+			name: "static keys in credentials file",
+			data: map[string][]byte{
+				"credentials": []byte("[other]\naws_access_key_id = AKIDOTHER\n[default]\n# comment\naws_access_key_id = AKIDFILE\naws_secret_access_key = secret\n"),
+			},
+			expectedKeyID: "AKIDFILE",
+		},
+		{
+			name: "role with local token file",
+			data: map[string][]byte{"credentials": roleFile(tokenFile)},
+		},
+		{
+			name:         "role with token fetcher",
+			data:         map[string][]byte{"credentials": roleFile("/nonexistent/token")},
+			tokenFetcher: fakeTokenFetcher{},
+		},
+		{
+			name:        "role without token",
+			data:        map[string][]byte{"credentials": roleFile("/nonexistent/token")},
+			expectedErr: `web identity token file "/nonexistent/token" of role arn:aws:iam::123456789012:role/efs not found`,
+		},
+		{
+			name:        "no credentials",
+			data:        map[string][]byte{"aws_access_key_id": []byte("AKIDSECRET")},
+			expectedErr: "neither aws_access_key_id and aws_secret_access_key nor credentials found",
+		},
+		{
+			name:        "credentials file without keys",
+			data:        map[string][]byte{"credentials": []byte("[default]\nregion = us-east-1\n")},
+			expectedErr: "credentials contain neither static keys nor role_arn",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secret := &corev1.Secret{Data: test.data}
+			creds, err := CredentialsFromSecret(secret, "us-east-1", "test", test.tokenFetcher)
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedKeyID == "" {
+				return
+			}
+			value, err := creds.Get()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value.AccessKeyID != test.expectedKeyID {
+				t.Errorf("expected key id %s, got %s", test.expectedKeyID, value.AccessKeyID)
+			}
+		})
+	}
+}
+
+func TestParseProviderID(t *testing.T) {
+	tests := []struct {
+		providerID         string
+		expectedZone       string
+		expectedInstanceID string
+	}{
+		{
+			providerID:         "aws:///us-west-2a/i-0304804a704fefb7d",
+			expectedZone:       "us-west-2a",
+			expectedInstanceID: "i-0304804a704fefb7d",
+		},
+		{
+			providerID:         "i-0304804a704fefb7d",
+			expectedInstanceID: "i-0304804a704fefb7d",
+		},
+		{
+			providerID: "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.providerID, func(t *testing.T) {
+			zone, instanceID := ParseProviderID(test.providerID)
+			if zone != test.expectedZone || instanceID != test.expectedInstanceID {
+				t.Errorf("expected zone %q and instance %q, got %q and %q", test.expectedZone, test.expectedInstanceID, zone, instanceID)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package awsutil
+
+import "strings"
+
+// ParseProviderID returns the zone and the instance ID from providerID of
+// a node of the form aws:///us-west-2a/i-0304804a704fefb7d. The zone is empty
+// when the providerID does not contain it.
+func ParseProviderID(providerID string) (zone string, instanceID string) {
+	parts := strings.Split(providerID, "/")
+	instanceID = parts[len(parts)-1]
+	if len(parts) >= 2 {
+		zone = parts[len(parts)-2]
+	}
+	return zone, instanceID
+}
@@ -0,0 +1,184 @@
+package efscreate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultAccessPointPermissions = "755"
+)
+
+// AccessPointOptions describes an EFS access point to create.
+type AccessPointOptions struct {
+	UID         int64
+	GID         int64
+	Path        string
+	Permissions string
+}
+
+// ParseAccessPoint parses access point specification in form of
+// <uid>:<gid>:<path>[:<permissions>], for example 1000:1000:/data:750.
+func ParseAccessPoint(spec string) (AccessPointOptions, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return AccessPointOptions{}, fmt.Errorf("invalid access point %q, expected <uid>:<gid>:<path>[:<permissions>]", spec)
+	}
+	uid, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || uid < 0 {
+		return AccessPointOptions{}, fmt.Errorf("invalid uid in access point %q", spec)
+	}
+	gid, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || gid < 0 {
+		return AccessPointOptions{}, fmt.Errorf("invalid gid in access point %q", spec)
+	}
+	if !strings.HasPrefix(parts[2], "/") {
+		return AccessPointOptions{}, fmt.Errorf("invalid path in access point %q, it must be absolute", spec)
+	}
+	ap := AccessPointOptions{
+		UID:         uid,
+		GID:         gid,
+		Path:        parts[2],
+		Permissions: defaultAccessPointPermissions,
+	}
+	if len(parts) == 4 {
+		if _, err := strconv.ParseUint(parts[3], 8, 32); err != nil || len(parts[3]) < 3 || len(parts[3]) > 4 {
+			return AccessPointOptions{}, fmt.Errorf("invalid permissions in access point %q, expected octal such as 750", spec)
+		}
+		ap.Permissions = parts[3]
+	}
+	return ap, nil
+}
+
+// ensureAccessPoints creates access points requested in Options.AccessPoints
+// that do not exist yet and returns IDs of all of them, in the same order.
+func (efs *EFS) ensureAccessPoints() ([]string, error) {
+	existing, err := efs.getAccessPoints()
+	if err != nil {
+		return nil, err
+	}
+
+	var accessPoints []string
+	for _, ap := range efs.options.AccessPoints {
+		apID := findAccessPoint(existing, ap)
+		if apID != "" {
+			log("using existing access point %s for %s", apID, ap.Path)
+		} else {
+			apID, err = efs.createAccessPoint(ap)
+			if err != nil {
+				return accessPoints, err
+			}
+		}
+		accessPoints = append(accessPoints, apID)
+	}
+
+	if len(accessPoints) > 0 {
+		err = efs.waitForAvailableAccessPoints()
+		if err != nil {
+			return accessPoints, fmt.Errorf("waiting for access points to be available failed: %v", err)
+		}
+	}
+	return accessPoints, nil
+}
+
+func findAccessPoint(existing []*awsefs.AccessPointDescription, ap AccessPointOptions) string {
+	for _, desc := range existing {
+		if desc.RootDirectory == nil || desc.PosixUser == nil {
+			continue
+		}
+		if aws.StringValue(desc.RootDirectory.Path) == ap.Path &&
+			aws.Int64Value(desc.PosixUser.Uid) == ap.UID &&
+			aws.Int64Value(desc.PosixUser.Gid) == ap.GID {
+			return *desc.AccessPointId
+		}
+	}
+	return ""
+}
+
+func (efs *EFS) getAccessPoints() ([]*awsefs.AccessPointDescription, error) {
+	var accessPoints []*awsefs.AccessPointDescription
+	input := &awsefs.DescribeAccessPointsInput{FileSystemId: aws.String(efs.resources.efsID)}
+	for {
+		response, err := efs.efsClient.DescribeAccessPoints(input)
+		if err != nil {
+			return nil, fmt.Errorf("error listing access points: %v", err)
+		}
+		for _, ap := range response.AccessPoints {
+			if aws.StringValue(ap.LifeCycleState) == awsefs.LifeCycleStateDeleting ||
+				aws.StringValue(ap.LifeCycleState) == awsefs.LifeCycleStateDeleted {
+				continue
+			}
+			accessPoints = append(accessPoints, ap)
+		}
+		if response.NextToken == nil || len(*response.NextToken) == 0 {
+			return accessPoints, nil
+		}
+		input.NextToken = response.NextToken
+	}
+}
+
+func (efs *EFS) createAccessPoint(ap AccessPointOptions) (string, error) {
+	input := &awsefs.CreateAccessPointInput{
+		FileSystemId: aws.String(efs.resources.efsID),
+		PosixUser: &awsefs.PosixUser{
+			Uid: aws.Int64(ap.UID),
+			Gid: aws.Int64(ap.GID),
+		},
+		RootDirectory: &awsefs.RootDirectory{
+			Path: aws.String(ap.Path),
+			CreationInfo: &awsefs.CreationInfo{
+				OwnerUid:    aws.Int64(ap.UID),
+				OwnerGid:    aws.Int64(ap.GID),
+				Permissions: aws.String(ap.Permissions),
+			},
+		},
+		Tags: efs.getEFSTags(efs.getVolumeName() + ap.Path),
+	}
+	response, err := efs.efsClient.CreateAccessPoint(input)
+	if err != nil {
+		return "", fmt.Errorf("error creating access point for %s: %v", ap.Path, err)
+	}
+	apID := *response.AccessPointId
+	log("created access point %s for %s", apID, ap.Path)
+	efs.registerUndo(fmt.Sprintf("access point %s", apID), func() error {
+		return efs.deleteAccessPoint(apID)
+	})
+	return apID, nil
+}
+
+func (efs *EFS) waitForAvailableAccessPoints() error {
+	return wait.ExponentialBackoff(efs.backoff, func() (bool, error) {
+		accessPoints, err := efs.getAccessPoints()
+		if err != nil {
+			return false, err
+		}
+		for _, ap := range accessPoints {
+			if *ap.LifeCycleState == awsefs.LifeCycleStateCreating {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+func (efs *EFS) deleteAccessPoint(apID string) error {
+	_, err := efs.efsClient.DeleteAccessPoint(&awsefs.DeleteAccessPointInput{
+		AccessPointId: aws.String(apID),
+	})
+	if err != nil {
+		if isAWSErrorCode(err, awsefs.ErrCodeAccessPointNotFound) {
+			klog.V(4).Infof("AccessPoint %s already removed", apID)
+			return nil
+		}
+		return fmt.Errorf("error deleting access point %s: %v", apID, err)
+	}
+	log("deleted access point %s", apID)
+	return nil
+}
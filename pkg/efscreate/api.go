@@ -0,0 +1,43 @@
+package efscreate
+
+import (
+	"context"
+
+	efsv2 "github.com/aws/aws-sdk-go-v2/service/efs"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+)
+
+// ec2API is the subset of the EC2 API used by create-efs-volume.
+type ec2API interface {
+	DescribeAvailabilityZones(*ec2.DescribeAvailabilityZonesInput) (*ec2.DescribeAvailabilityZonesOutput, error)
+	DescribeInstances(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error)
+	DescribeVpcs(*ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error)
+	DescribeSubnets(*ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error)
+	DescribeSecurityGroups(*ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error)
+	CreateSecurityGroup(*ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error)
+	DeleteSecurityGroup(*ec2.DeleteSecurityGroupInput) (*ec2.DeleteSecurityGroupOutput, error)
+	AuthorizeSecurityGroupIngress(*ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error)
+	RevokeSecurityGroupIngress(*ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error)
+	CreateTags(*ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error)
+}
+
+// efsAPI is the subset of the EFS API used by create-efs-volume.
+type efsAPI interface {
+	CreateFileSystem(*awsefs.CreateFileSystemInput) (*awsefs.FileSystemDescription, error)
+	DescribeFileSystems(*awsefs.DescribeFileSystemsInput) (*awsefs.DescribeFileSystemsOutput, error)
+	DeleteFileSystem(*awsefs.DeleteFileSystemInput) (*awsefs.DeleteFileSystemOutput, error)
+	PutLifecycleConfiguration(*awsefs.PutLifecycleConfigurationInput) (*awsefs.PutLifecycleConfigurationOutput, error)
+	PutFileSystemPolicy(*awsefs.PutFileSystemPolicyInput) (*awsefs.PutFileSystemPolicyOutput, error)
+	DescribeMountTargets(*awsefs.DescribeMountTargetsInput) (*awsefs.DescribeMountTargetsOutput, error)
+	DeleteMountTarget(*awsefs.DeleteMountTargetInput) (*awsefs.DeleteMountTargetOutput, error)
+	CreateAccessPoint(*awsefs.CreateAccessPointInput) (*awsefs.CreateAccessPointOutput, error)
+	DescribeAccessPoints(*awsefs.DescribeAccessPointsInput) (*awsefs.DescribeAccessPointsOutput, error)
+	DeleteAccessPoint(*awsefs.DeleteAccessPointInput) (*awsefs.DeleteAccessPointOutput, error)
+}
+
+// mountTargetAPI creates EFS mount targets. It uses AWS SDK v2, which knows
+// IpAddressType of CreateMountTargetInput.
+type mountTargetAPI interface {
+	CreateMountTarget(context.Context, *efsv2.CreateMountTargetInput, ...func(*efsv2.Options)) (*efsv2.CreateMountTargetOutput, error)
+}
@@ -11,9 +11,6 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/openshift/aws-efs-csi-driver-operator/assets"
 	configclient "github.com/openshift/client-go/config/clientset/versioned"
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
@@ -26,15 +23,17 @@ import (
 const (
 	operatorName          = "create-efs-volume"
 	infraGlobalName       = "cluster"
-	secretNamespace       = "kube-system"
-	secretName            = "aws-creds"
 	storageClassName      = "efs-sc"
 	STORAGECLASS_LOCATION = "STORAGECLASS_LOCATION"
 	MANIFEST_LOCATION     = "MANIFEST_LOCATION"
 	fileMode              = 0640
 )
 
-func RunOperator(ctx context.Context, controllerConfig *controllercmd.ControllerContext, useLocalAWSCredentials bool) error {
+func RunOperator(ctx context.Context, controllerConfig *controllercmd.ControllerContext, options Options) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
 	// Create core clientset for core and infra objects
 	kubeClient := kubeclient.NewForConfigOrDie(rest.AddUserAgent(controllerConfig.KubeConfig, operatorName))
 	nodes, err := getNodes(ctx, kubeClient)
@@ -43,30 +42,32 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		return fmt.Errorf("error listing nodes: %v", err)
 	}
 
-	configClient := configclient.NewForConfigOrDie(rest.AddUserAgent(controllerConfig.KubeConfig, operatorName))
-	infra, err := getInfra(ctx, configClient)
+	efs, err := newEFSForCluster(ctx, controllerConfig, kubeClient, options)
 	if err != nil {
-		klog.Errorf("error listing infrastructures objects: %v", err)
-		return fmt.Errorf("error listing infrastructure objects: %v", err)
+		return err
 	}
-	region := infra.Status.PlatformStatus.AWS.Region
-	klog.V(2).Infof("Detected AWS region from the OCP cluster: %s", region)
 
-	ec2Session, err := getEC2Client(ctx, useLocalAWSCredentials, kubeClient, region)
-	if err != nil {
-		klog.Errorf("error getting aws client: %v", err)
-		return fmt.Errorf("error getting aws client: %v", err)
+	if options.DryRun {
+		plan, err := efs.PlanEFSVolume(nodes)
+		if err != nil {
+			klog.Errorf("error planning efs volume: %v", err)
+			return err
+		}
+		klog.Infof("dry run, no AWS resources were changed")
+		return writePlan(os.Stdout, plan)
 	}
 
-	efs := NewEFSSession(infra, ec2Session)
-
 	fsID, err := efs.CreateEFSVolume(nodes)
 	if err != nil {
 		klog.Errorf("error creating efs volume: %v", err)
 		return err
 	}
 	klog.Infof("created fsID: %s", fsID)
-	err = writeStorageClassFile(fsID)
+	crossAccountZone := ""
+	if options.CrossAccount.Enabled() {
+		crossAccountZone = efs.getCrossAccountZone()
+	}
+	err = writeStorageClassFile(fsID, options.OneZone, crossAccountZone)
 	if err != nil {
 		klog.Errorf("error writing storageclass to location %s: %v", os.Getenv(STORAGECLASS_LOCATION), err)
 		return err
@@ -77,9 +78,95 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		return err
 	}
 
+	if len(options.StaticPVFile) > 0 {
+		err = writeStaticPVFile(options.StaticPVFile, fsID, efs.resources.accessPoints)
+		if err != nil {
+			klog.Errorf("error writing static volumes to location %s: %v", options.StaticPVFile, err)
+			return err
+		}
+	}
+
+	if len(options.ReportFile) > 0 {
+		report, err := efs.GetReport()
+		if err != nil {
+			klog.Errorf("error creating report: %v", err)
+			return err
+		}
+		err = writeReport(options.ReportFile, report)
+		if err != nil {
+			klog.Errorf("error writing report to location %s: %v", options.ReportFile, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunDestroy deletes the EFS volume and all AWS resources created by RunOperator.
+func RunDestroy(ctx context.Context, controllerConfig *controllercmd.ControllerContext, options DestroyOptions) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	kubeClient := kubeclient.NewForConfigOrDie(rest.AddUserAgent(controllerConfig.KubeConfig, operatorName))
+	nodes, err := getNodes(ctx, kubeClient)
+	if err != nil {
+		klog.Errorf("error listing nodes: %v", err)
+		return fmt.Errorf("error listing nodes: %v", err)
+	}
+
+	efs, err := newEFSForCluster(ctx, controllerConfig, kubeClient, options.createOptions())
+	if err != nil {
+		return err
+	}
+
+	err = efs.DeleteEFSVolume(nodes)
+	if err != nil {
+		klog.Errorf("error deleting efs volume: %v", err)
+		return err
+	}
 	return nil
 }
 
+func newEFSForCluster(
+	ctx context.Context,
+	controllerConfig *controllercmd.ControllerContext,
+	kubeClient *kubeclient.Clientset,
+	options Options) (*EFS, error) {
+
+	configClient := configclient.NewForConfigOrDie(rest.AddUserAgent(controllerConfig.KubeConfig, operatorName))
+	infra, err := getInfra(ctx, configClient)
+	if err != nil {
+		klog.Errorf("error listing infrastructures objects: %v", err)
+		return nil, fmt.Errorf("error listing infrastructure objects: %v", err)
+	}
+	if err := validateInfrastructure(infra); err != nil {
+		return nil, err
+	}
+	region := infra.Status.PlatformStatus.AWS.Region
+	klog.V(2).Infof("Detected AWS region from the OCP cluster: %s", region)
+	if options.OneZone != "" && !strings.HasPrefix(options.OneZone, region) {
+		return nil, fmt.Errorf("availability zone %s is not in the cluster region %s", options.OneZone, region)
+	}
+
+	ec2Session, err := getEC2Client(ctx, options, kubeClient, controllerConfig.OperatorNamespace, region)
+	if err != nil {
+		klog.Errorf("error getting aws client: %v", err)
+		return nil, fmt.Errorf("error getting aws client: %v", err)
+	}
+
+	if crossAccount := options.CrossAccount; crossAccount.Enabled() {
+		klog.V(2).Infof("Assuming role %s in the filesystem account", crossAccount.RoleARN)
+		fsSession, err := assumeRole(ec2Session, crossAccount.RoleARN, crossAccount.ExternalID)
+		if err != nil {
+			klog.Errorf("error getting aws client of the filesystem account: %v", err)
+			return nil, fmt.Errorf("error getting aws client of the filesystem account: %v", err)
+		}
+		return NewCrossAccountEFSSession(infra, ec2Session, fsSession, options), nil
+	}
+	return NewEFSSession(infra, ec2Session, options), nil
+}
+
 func getInfra(ctx context.Context, infraClient *configclient.Clientset) (infra *v1.Infrastructure, err error) {
 	backoff := wait.Backoff{
 		Duration: operationDelay,
@@ -101,28 +188,6 @@ func getInfra(ctx context.Context, infraClient *configclient.Clientset) (infra *
 	return
 }
 
-func getSecret(ctx context.Context, client *kubeclient.Clientset) (*corev1.Secret, error) {
-	backoff := wait.Backoff{
-		Duration: operationDelay,
-		Factor:   operationBackoffFactor,
-		Steps:    operationRetryCount,
-	}
-	var awsCreds *corev1.Secret
-	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
-		var apiError error
-		awsCreds, apiError = client.CoreV1().Secrets(secretNamespace).Get(ctx, secretName, metav1.GetOptions{})
-		if apiError != nil {
-			klog.Errorf("error getting secret object: %v", apiError)
-			return false, nil
-		}
-		if awsCreds != nil {
-			return true, nil
-		}
-		return false, nil
-	})
-	return awsCreds, err
-}
-
 func getNodes(ctx context.Context, client *kubeclient.Clientset) (*corev1.NodeList, error) {
 	backoff := wait.Backoff{
 		Duration: operationDelay,
@@ -145,7 +210,11 @@ func getNodes(ctx context.Context, client *kubeclient.Clientset) (*corev1.NodeLi
 	return nodes, err
 }
 
-func writeStorageClassFile(fsID string) error {
+// writeStorageClassFile writes a StorageClass for the file system. When zone is
+// set, volumes of the StorageClass can be used only by nodes in that zone. When
+// crossAccountZone is set, volumes are mounted from another account through the
+// mount target in that zone.
+func writeStorageClassFile(fsID string, zone string, crossAccountZone string) error {
 	fileName := os.Getenv(STORAGECLASS_LOCATION)
 	if len(fileName) == 0 {
 		return fmt.Errorf("no storageclass location specified")
@@ -155,10 +224,26 @@ func writeStorageClassFile(fsID string) error {
 	if err != nil {
 		return err
 	}
+	if crossAccountZone != "" {
+		crossAccountContentBytes, err := assets.ReadFile("testing/sc_cross_account.yaml")
+		if err != nil {
+			return err
+		}
+		scContentBytes = append(scContentBytes, crossAccountContentBytes...)
+	}
+	if zone != "" {
+		topologyContentBytes, err := assets.ReadFile("testing/sc_allowed_topologies.yaml")
+		if err != nil {
+			return err
+		}
+		scContentBytes = append(scContentBytes, topologyContentBytes...)
+	}
 	scContent := string(scContentBytes)
 	replaceStrings := []string{
 		"${storageclassname}", storageClassName,
 		"${filesystemid}", fsID,
+		"${availabilityzone}", zone,
+		"${crossaccountzone}", crossAccountZone,
 	}
 	replacer := strings.NewReplacer(replaceStrings...)
 	finalSCContent := replacer.Replace(scContent)
@@ -182,40 +267,30 @@ func writeCSIManifest(scName string) error {
 	return err
 }
 
-func getEC2Client(
-	ctx context.Context,
-	useLocalAWSCreds bool,
-	client *kubeclient.Clientset,
-	region string) (*session.Session, error) {
-
-	cfg := &aws.Config{
-		Region: aws.String(region),
+// writeStaticPVFile writes a PersistentVolume and a PersistentVolumeClaim for
+// each access point, or for the root of the file system when there are none.
+func writeStaticPVFile(fileName string, fsID string, accessPoints []string) error {
+	pvContentBytes, err := assets.ReadFile("testing/static_pv.yaml")
+	if err != nil {
+		return err
 	}
 
-	if !useLocalAWSCreds {
-		// Use credentials from the cluster Secret
-		awsCreds, err := getSecret(ctx, client)
-		if err != nil {
-			return nil, err
+	volumeHandles := []string{fsID}
+	if len(accessPoints) > 0 {
+		volumeHandles = []string{}
+		for _, apID := range accessPoints {
+			volumeHandles = append(volumeHandles, fmt.Sprintf("%s::%s", fsID, apID))
 		}
-		id, found := awsCreds.Data["aws_access_key_id"]
-		if !found {
-			return nil, fmt.Errorf("cloud credential id not found")
-		}
-		key, found := awsCreds.Data["aws_secret_access_key"]
-		if !found {
-			return nil, fmt.Errorf("cloud credential key not found")
-		}
-
-		klog.V(2).Infof("Using AWS credentials from the cluster, got key id: %s", id)
-		cfg.Credentials = credentials.NewStaticCredentials(string(id), string(key), "")
-	} else {
-		klog.V(2).Infof("Using AWS credentials from local machine, either env. vars or ~/.aws/config")
 	}
 
-	sess, err := session.NewSession(cfg)
-	if err != nil {
-		return nil, err
+	var manifests []string
+	for i, volumeHandle := range volumeHandles {
+		replaceStrings := []string{
+			"${pvname}", fmt.Sprintf("%s-static-%d", storageClassName, i),
+			"${volumehandle}", volumeHandle,
+		}
+		replacer := strings.NewReplacer(replaceStrings...)
+		manifests = append(manifests, replacer.Replace(string(pvContentBytes)))
 	}
-	return sess, nil
+	return ioutil.WriteFile(fileName, []byte(strings.Join(manifests, "---\n")), fileMode)
 }
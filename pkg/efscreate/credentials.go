@@ -0,0 +1,228 @@
+package efscreate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubeclient "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/aws-efs-csi-driver-operator/pkg/awsutil"
+)
+
+const (
+	secretNamespace = "kube-system"
+	secretName      = "aws-creds"
+	// Secret created for the operator by cloud-credential-operator
+	operatorSecretName = "aws-efs-cloud-credentials"
+	// Service account whose token is used for STS, when the token file from
+	// the operator secret is not available locally
+	controllerServiceAccountName = "aws-efs-csi-driver-controller-sa"
+	webIdentityTokenAudience     = "openshift"
+	roleSessionName              = operatorName
+)
+
+// CredentialsOptions selects where create-efs-volume gets AWS credentials.
+type CredentialsOptions struct {
+	// SecretNamespace and SecretName select a Secret with the credentials.
+	// When empty, kube-system/aws-creds and the operator's own
+	// aws-efs-cloud-credentials Secret are tried.
+	SecretNamespace string
+	SecretName      string
+	// Profile is a named profile from the local AWS config. It implies local
+	// credentials.
+	Profile string
+	// AssumeRoleARN is a role assumed with the credentials from any of the
+	// sources above.
+	AssumeRoleARN string
+	// ExternalID is passed when assuming AssumeRoleARN.
+	ExternalID string
+}
+
+// Validate checks the credentials options before any AWS call is made.
+func (o *CredentialsOptions) Validate(useLocalAWSCredentials bool) error {
+	if (useLocalAWSCredentials || o.Profile != "") && (o.SecretNamespace != "" || o.SecretName != "") {
+		return fmt.Errorf("credentials secret can't be used with local AWS credentials")
+	}
+	if o.AssumeRoleARN == "" {
+		if o.ExternalID != "" {
+			return fmt.Errorf("external ID requires a role ARN to assume")
+		}
+		return nil
+	}
+	if !strings.HasPrefix(o.AssumeRoleARN, "arn:") {
+		return fmt.Errorf("invalid role ARN %q", o.AssumeRoleARN)
+	}
+	return nil
+}
+
+func getEC2Client(
+	ctx context.Context,
+	options Options,
+	client *kubeclient.Clientset,
+	operatorNamespace string,
+	region string) (*session.Session, error) {
+
+	cfg := &aws.Config{
+		Region: aws.String(region),
+	}
+	credsOptions := options.Credentials
+
+	var sess *session.Session
+	var err error
+	if options.UseLocalAWSCredentials || credsOptions.Profile != "" {
+		sessionOptions := session.Options{Config: *cfg}
+		if credsOptions.Profile != "" {
+			klog.V(2).Infof("Using AWS credentials from local profile %s", credsOptions.Profile)
+			sessionOptions.Profile = credsOptions.Profile
+			sessionOptions.SharedConfigState = session.SharedConfigEnable
+		} else {
+			klog.V(2).Infof("Using AWS credentials from local machine, either env. vars or ~/.aws/config")
+		}
+		sess, err = session.NewSessionWithOptions(sessionOptions)
+	} else {
+		// Use credentials from the cluster Secret
+		cfg.Credentials, err = getClusterCredentials(ctx, credsOptions, client, operatorNamespace, region)
+		if err != nil {
+			return nil, err
+		}
+		sess, err = session.NewSession(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if credsOptions.AssumeRoleARN != "" {
+		klog.V(2).Infof("Assuming role %s", credsOptions.AssumeRoleARN)
+		return assumeRole(sess, credsOptions.AssumeRoleARN, credsOptions.ExternalID)
+	}
+	return sess, nil
+}
+
+// assumeRole returns a session with credentials of the role, assumed with
+// credentials of the given session.
+func assumeRole(sess *session.Session, roleARN string, externalID string) (*session.Session, error) {
+	roleCreds := stscreds.NewCredentials(sess, roleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = roleSessionName
+		if externalID != "" {
+			p.ExternalID = aws.String(externalID)
+		}
+	})
+	if _, err := roleCreds.Get(); err != nil {
+		return nil, fmt.Errorf("error assuming role %s: %v", roleARN, err)
+	}
+	return session.NewSession(sess.Config.Copy().WithCredentials(roleCreds))
+}
+
+// getClusterCredentials returns credentials from the first usable Secret. The
+// error lists all Secrets that were tried.
+func getClusterCredentials(
+	ctx context.Context,
+	options CredentialsOptions,
+	client *kubeclient.Clientset,
+	operatorNamespace string,
+	region string) (*credentials.Credentials, error) {
+
+	sources := [][]string{
+		{secretNamespace, secretName},
+		{operatorNamespace, operatorSecretName},
+	}
+	if options.SecretName != "" {
+		namespace := options.SecretNamespace
+		if namespace == "" {
+			namespace = operatorNamespace
+		}
+		sources = [][]string{{namespace, options.SecretName}}
+	}
+
+	var tried []string
+	for _, source := range sources {
+		namespace, name := source[0], source[1]
+		if namespace == "" {
+			continue
+		}
+		awsCreds, err := getSecret(ctx, client, namespace, name)
+		if err != nil {
+			tried = append(tried, fmt.Sprintf("secret %s/%s: %v", namespace, name, err))
+			continue
+		}
+		tokenFetcher := &serviceAccountTokenFetcher{
+			client:    client,
+			namespace: namespace,
+			name:      controllerServiceAccountName,
+		}
+		creds, err := awsutil.CredentialsFromSecret(awsCreds, region, roleSessionName, tokenFetcher)
+		if err == nil {
+			// Static credentials always succeed, web identity fails here when the role can't be assumed
+			_, err = creds.Get()
+		}
+		if err != nil {
+			tried = append(tried, fmt.Sprintf("secret %s/%s: %v", namespace, name, err))
+			continue
+		}
+		klog.V(2).Infof("Using AWS credentials from secret %s/%s", namespace, name)
+		return creds, nil
+	}
+	return nil, fmt.Errorf("no usable AWS credentials found, tried: [%s]", strings.Join(tried, "; "))
+}
+
+// serviceAccountTokenFetcher requests a bound service account token for STS.
+// It's used when running outside of the cluster, where the token file from
+// the Secret is not available.
+type serviceAccountTokenFetcher struct {
+	client    kubeclient.Interface
+	namespace string
+	name      string
+}
+
+func (f *serviceAccountTokenFetcher) FetchToken(ctx credentials.Context) ([]byte, error) {
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences: []string{webIdentityTokenAudience},
+		},
+	}
+	response, err := f.client.CoreV1().ServiceAccounts(f.namespace).CreateToken(ctx, f.name, tokenRequest, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error requesting token for service account %s/%s: %v", f.namespace, f.name, err)
+	}
+	return []byte(response.Status.Token), nil
+}
+
+func getSecret(ctx context.Context, client *kubeclient.Clientset, namespace, name string) (*corev1.Secret, error) {
+	backoff := wait.Backoff{
+		Duration: operationDelay,
+		Factor:   operationBackoffFactor,
+		Steps:    operationRetryCount,
+	}
+	var awsCreds *corev1.Secret
+	var lastErr error
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		awsCreds, lastErr = client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(lastErr) {
+			// No point in retrying, try the next source
+			return false, lastErr
+		}
+		if lastErr != nil {
+			klog.Errorf("error getting secret object: %v", lastErr)
+			return false, nil
+		}
+		if awsCreds != nil {
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil && lastErr != nil {
+		return nil, lastErr
+	}
+	return awsCreds, err
+}
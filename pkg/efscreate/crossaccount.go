@@ -0,0 +1,205 @@
+package efscreate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// CrossAccountOptions configures creation of the file system in a different
+// AWS account than the cluster, with mount targets in a VPC that is peered
+// with or shared to the cluster VPC.
+type CrossAccountOptions struct {
+	// RoleARN is a role in the file system account, assumed with the cluster
+	// credentials. Empty disables the cross-account mode.
+	RoleARN string
+	// ExternalID is passed when assuming RoleARN.
+	ExternalID string
+	// VPCID is the VPC of the file system account where the security group and
+	// mount targets are created.
+	VPCID string
+	// DriverRoleARN is the IAM role of the CSI driver in the cluster account,
+	// the file system policy allows it to mount the file system.
+	DriverRoleARN string
+}
+
+// Enabled returns true when the file system is created in another account.
+func (o *CrossAccountOptions) Enabled() bool {
+	return o.RoleARN != ""
+}
+
+// Validate checks the cross-account options before any AWS call is made.
+func (o *CrossAccountOptions) Validate() error {
+	if !o.Enabled() {
+		if o.ExternalID != "" || o.VPCID != "" || o.DriverRoleARN != "" {
+			return fmt.Errorf("cross-account options require a role ARN in the filesystem account")
+		}
+		return nil
+	}
+	for _, roleARN := range []string{o.RoleARN, o.DriverRoleARN} {
+		if !strings.HasPrefix(roleARN, "arn:") {
+			return fmt.Errorf("invalid role ARN %q", roleARN)
+		}
+	}
+	if o.VPCID == "" {
+		return fmt.Errorf("cross-account mode requires a VPC in the filesystem account")
+	}
+	return nil
+}
+
+// validateRole checks only the role in the filesystem account, the VPC and
+// the driver role are not needed to find existing resources.
+func (o *CrossAccountOptions) validateRole() error {
+	if !o.Enabled() {
+		if o.ExternalID != "" {
+			return fmt.Errorf("cross-account options require a role ARN in the filesystem account")
+		}
+		return nil
+	}
+	if !strings.HasPrefix(o.RoleARN, "arn:") {
+		return fmt.Errorf("invalid role ARN %q", o.RoleARN)
+	}
+	return nil
+}
+
+// selectCrossAccountSubnets chooses one subnet of the VPC in the file system
+// account in each availability zone of the cluster instances. Zone names are
+// mapped to different zones in each account, zones are matched by their IDs.
+func (efs *EFS) selectCrossAccountSubnets(instances []*ec2.Instance) error {
+	if len(efs.options.SubnetIDs) > 0 {
+		return efs.selectExplicitSubnets()
+	}
+
+	clusterZones, err := getZoneIDs(efs.client)
+	if err != nil {
+		return err
+	}
+	zoneIDs := sets.NewString()
+	for _, instance := range instances {
+		if instance.Placement == nil {
+			continue
+		}
+		zoneIDs.Insert(clusterZones[aws.StringValue(instance.Placement.AvailabilityZone)])
+	}
+
+	input := &ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(efs.vpcID)},
+			},
+		},
+	}
+	zoneSubnets := map[string]sets.String{}
+	for {
+		response, err := efs.fsAccountClient.DescribeSubnets(input)
+		if err != nil {
+			return fmt.Errorf("error listing subnets: %v", err)
+		}
+		for _, subnet := range response.Subnets {
+			if !zoneIDs.Has(aws.StringValue(subnet.AvailabilityZoneId)) {
+				continue
+			}
+			zone := aws.StringValue(subnet.AvailabilityZone)
+			if _, found := zoneSubnets[zone]; !found {
+				zoneSubnets[zone] = sets.NewString()
+			}
+			zoneSubnets[zone].Insert(*subnet.SubnetId)
+		}
+		if response.NextToken == nil || len(*response.NextToken) == 0 {
+			break
+		}
+		input.NextToken = response.NextToken
+	}
+	return efs.setSubnetsByZone(zoneSubnets)
+}
+
+// getZoneIDs maps names of availability zones of an account to their IDs.
+func getZoneIDs(client ec2API) (map[string]string, error) {
+	response, err := client.DescribeAvailabilityZones(&ec2.DescribeAvailabilityZonesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing availability zones: %v", err)
+	}
+	zoneIDs := map[string]string{}
+	for _, zone := range response.AvailabilityZones {
+		zoneIDs[aws.StringValue(zone.ZoneName)] = aws.StringValue(zone.ZoneId)
+	}
+	return zoneIDs, nil
+}
+
+// getCrossAccountZone returns the zone of the mount target used by the CSI
+// driver for cross-account mounts, the first one in alphabetical order.
+func (efs *EFS) getCrossAccountZone() string {
+	var zones []string
+	for _, subnet := range efs.subnetIDs {
+		zones = append(zones, efs.subnetZones[subnet])
+	}
+	if len(zones) == 0 {
+		return ""
+	}
+	sort.Strings(zones)
+	return zones[0]
+}
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Sid       string            `json:"Sid"`
+	Effect    string            `json:"Effect"`
+	Principal map[string]string `json:"Principal"`
+	Action    []string          `json:"Action"`
+	Resource  string            `json:"Resource"`
+}
+
+// putFileSystemPolicy allows the CSI driver role of the cluster to mount the
+// file system. An existing policy is replaced.
+func (efs *EFS) putFileSystemPolicy(efsID string) error {
+	response, err := efs.efsClient.DescribeFileSystems(&awsefs.DescribeFileSystemsInput{
+		FileSystemId: aws.String(efsID),
+	})
+	if err != nil {
+		return fmt.Errorf("error describing filesystem %s: %v", efsID, err)
+	}
+	if len(response.FileSystems) < 1 {
+		return fmt.Errorf("filesystem %s not found", efsID)
+	}
+
+	policy := policyDocument{
+		Version: "2012-10-17",
+		Statement: []policyStatement{
+			{
+				Sid:       "AllowClusterCSIDriver",
+				Effect:    "Allow",
+				Principal: map[string]string{"AWS": efs.options.CrossAccount.DriverRoleARN},
+				Action: []string{
+					"elasticfilesystem:ClientMount",
+					"elasticfilesystem:ClientWrite",
+					"elasticfilesystem:ClientRootAccess",
+				},
+				Resource: aws.StringValue(response.FileSystems[0].FileSystemArn),
+			},
+		},
+	}
+	content, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	_, err = efs.efsClient.PutFileSystemPolicy(&awsefs.PutFileSystemPolicyInput{
+		FileSystemId: aws.String(efsID),
+		Policy:       aws.String(string(content)),
+	})
+	if err != nil {
+		return fmt.Errorf("error setting policy of filesystem %s: %v", efsID, err)
+	}
+	log("allowed %s to mount filesystem %s", efs.options.CrossAccount.DriverRoleARN, efsID)
+	return nil
+}
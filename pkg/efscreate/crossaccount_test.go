@@ -0,0 +1,123 @@
+package efscreate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const testDriverRoleARN = "arn:aws:iam::111111111111:role/test-abcde-aws-efs-csi-driver"
+
+func TestCreateEFSVolumeCrossAccount(t *testing.T) {
+	cluster := newTestCluster()
+	// The same zones have different names in the filesystem account
+	fsAccount := newFakeAWS()
+	fsAccount.zoneIDs = map[string]string{
+		"us-east-1a": "use1-azb",
+		"us-east-1b": "use1-azc",
+		"us-east-1c": "use1-aza",
+	}
+	fsAccount.withVPC("vpc-shared", "172.16.0.0/16").
+		withSubnet("vpc-shared", "subnet-shared-a", "us-east-1a").
+		withSubnet("vpc-shared", "subnet-shared-b", "us-east-1b").
+		withSubnet("vpc-shared", "subnet-shared-c", "us-east-1c").
+		withSubnet("vpc-other", "subnet-other", "us-east-1a")
+
+	options := newTestOptions()
+	options.CrossAccount = CrossAccountOptions{
+		RoleARN:       "arn:aws:iam::222222222222:role/efs-admin",
+		VPCID:         "vpc-shared",
+		DriverRoleARN: testDriverRoleARN,
+	}
+	if err := options.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	efs := newTestEFS(cluster, options)
+	efs.fsAccountClient = fsAccount
+	efs.efsClient = fsAccount
+	efs.mountTargetClient = fsAccount
+
+	// Nodes are in us-east-1a and us-east-1b of the cluster account
+	fsID, err := efs.CreateEFSVolume(newTestNodes("i-1", "i-2"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cluster.securityGroups) != 0 || len(cluster.fileSystems) != 0 {
+		t.Errorf("expected no resources in the cluster account")
+	}
+	var subnets []string
+	for _, id := range sortedKeys(fsAccount.mountTargets) {
+		subnets = append(subnets, *fsAccount.mountTargets[id].SubnetId)
+	}
+	if strings.Join(subnets, ",") != "subnet-shared-a,subnet-shared-c" {
+		t.Errorf("expected mount targets in zones of the cluster nodes, got subnets %v", subnets)
+	}
+	if len(fsAccount.securityGroups) != 1 {
+		t.Fatalf("expected a security group in the filesystem account, got %d", len(fsAccount.securityGroups))
+	}
+	for _, sg := range fsAccount.securityGroups {
+		if aws.StringValue(sg.VpcId) != "vpc-shared" {
+			t.Errorf("expected security group in vpc-shared, got %s", aws.StringValue(sg.VpcId))
+		}
+		if len(sg.IpPermissions) != 1 || aws.StringValue(sg.IpPermissions[0].IpRanges[0].CidrIp) != "10.0.0.0/16" {
+			t.Errorf("expected NFS allowed from the cluster VPC, got %v", sg.IpPermissions)
+		}
+	}
+	if policy := fsAccount.policies[fsID]; !strings.Contains(policy, testDriverRoleARN) || !strings.Contains(policy, "elasticfilesystem:ClientMount") {
+		t.Errorf("expected filesystem policy allowing the driver role, got %q", policy)
+	}
+	if zone := efs.getCrossAccountZone(); zone != "us-east-1a" {
+		t.Errorf("expected us-east-1a for cross-account mounts, got %s", zone)
+	}
+}
+
+func TestCrossAccountOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     func(*Options)
+		expectedErr string
+	}{
+		{
+			name:    "disabled",
+			options: func(o *Options) {},
+		},
+		{
+			name: "VPC without role",
+			options: func(o *Options) {
+				o.CrossAccount.VPCID = "vpc-shared"
+			},
+			expectedErr: "require a role ARN",
+		},
+		{
+			name: "missing driver role",
+			options: func(o *Options) {
+				o.CrossAccount = CrossAccountOptions{RoleARN: "arn:aws:iam::222222222222:role/efs-admin", VPCID: "vpc-shared"}
+			},
+			expectedErr: "invalid role ARN",
+		},
+		{
+			name: "missing VPC",
+			options: func(o *Options) {
+				o.CrossAccount = CrossAccountOptions{RoleARN: "arn:aws:iam::222222222222:role/efs-admin", DriverRoleARN: testDriverRoleARN}
+			},
+			expectedErr: "requires a VPC",
+		},
+		{
+			name: "node security groups",
+			options: func(o *Options) {
+				o.CrossAccount = CrossAccountOptions{RoleARN: "arn:aws:iam::222222222222:role/efs-admin", VPCID: "vpc-shared", DriverRoleARN: testDriverRoleARN}
+				o.IngressSource = IngressSourceNodeSecurityGroups
+			},
+			expectedErr: "supports only vpc-cidr ingress source",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := newTestOptions()
+			test.options(&options)
+			checkError(t, options.Validate(), test.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package efscreate
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	dependencyViolationErrorCode = "DependencyViolation"
+)
+
+// DeleteEFSVolume removes the security group, file system, mount targets and
+// access points created by CreateEFSVolume. Resources are discovered by their names and the
+// cluster tag, so it works also for resources created by a different run. The
+// nodes are used to find the cluster VPC, so only the security group of this
+// cluster is deleted.
+func (efs *EFS) DeleteEFSVolume(nodes *corev1.NodeList) error {
+	klog.V(4).Info("Loading AWS VPC")
+	err := efs.getVPCID(efs.getInstanceIDs(nodes))
+	if err != nil {
+		return err
+	}
+
+	klog.V(4).Info("Looking for resources owned by the cluster")
+	err = efs.findOwnedResources()
+	if err != nil {
+		return err
+	}
+
+	if efs.resources.efsID != "" {
+		klog.V(4).Info("Deleting AccessPoints")
+		for _, apID := range efs.resources.accessPoints {
+			err = efs.deleteAccessPoint(apID)
+			if err != nil {
+				return err
+			}
+		}
+
+		klog.V(4).Info("Deleting MountTargets")
+		for _, mtID := range efs.resources.mountTargets {
+			err = efs.deleteMountTarget(mtID)
+			if err != nil {
+				return err
+			}
+		}
+
+		klog.V(4).Info("Waiting for MountTargets to get deleted")
+		err = efs.waitForDeletedMountTargets(efs.resources.efsID)
+		if err != nil {
+			return fmt.Errorf("waiting for mount targets to be deleted failed: %v", err)
+		}
+
+		klog.V(4).Info("Deleting EFS volume")
+		err = efs.deleteEFSFileSystem(efs.resources.efsID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if efs.resources.securityGroupID != "" {
+		klog.V(4).Info("Deleting SecurityGroup")
+		err = efs.deleteSecurityGroup(efs.resources.securityGroupID)
+		if err != nil {
+			return err
+		}
+	}
+	log("successfully deleted resources of cluster %s", efs.infra.Status.InfrastructureName)
+	return nil
+}
+
+func (efs *EFS) findOwnedResources() error {
+	fs, err := efs.findEFSFileSystem()
+	if err != nil {
+		return err
+	}
+	if fs != nil {
+		efs.resources.efsID = *fs.FileSystemId
+		mts, err := efs.getMountTargetsBySubnet()
+		if err != nil {
+			return err
+		}
+		for _, mtID := range mts {
+			efs.resources.mountTargets = append(efs.resources.mountTargets, mtID)
+		}
+		aps, err := efs.getAccessPoints()
+		if err != nil {
+			return err
+		}
+		for _, ap := range aps {
+			efs.resources.accessPoints = append(efs.resources.accessPoints, *ap.AccessPointId)
+		}
+	} else {
+		log("no filesystem %s found", efs.getVolumeName())
+	}
+
+	input := &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("group-name"),
+				Values: []*string{aws.String(efs.getSecurityGroupName())},
+			},
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(efs.vpcID)},
+			},
+			{
+				Name:   aws.String("tag:" + efs.getClusterTagKey()),
+				Values: []*string{aws.String("owned")},
+			},
+		},
+	}
+	response, err := efs.fsAccountClient.DescribeSecurityGroups(input)
+	if err != nil {
+		return fmt.Errorf("error listing security groups: %v", err)
+	}
+	if len(response.SecurityGroups) > 0 {
+		efs.resources.securityGroupID = *response.SecurityGroups[0].GroupId
+	} else {
+		log("no security group %s found", efs.getSecurityGroupName())
+	}
+	return nil
+}
+
+func (efs *EFS) deleteMountTarget(mtID string) error {
+	_, err := efs.efsClient.DeleteMountTarget(&awsefs.DeleteMountTargetInput{
+		MountTargetId: aws.String(mtID),
+	})
+	if err != nil {
+		if isAWSErrorCode(err, awsefs.ErrCodeMountTargetNotFound) {
+			klog.V(4).Infof("MountTarget %s already removed", mtID)
+			return nil
+		}
+		return fmt.Errorf("error deleting mount target %s: %v", mtID, err)
+	}
+	log("deleted mount target %s", mtID)
+	return nil
+}
+
+func (efs *EFS) waitForDeletedMountTargets(efsID string) error {
+	describeInput := &awsefs.DescribeMountTargetsInput{FileSystemId: aws.String(efsID)}
+	err := wait.ExponentialBackoff(efs.backoff, func() (bool, error) {
+		response, describeErr := efs.efsClient.DescribeMountTargets(describeInput)
+		if describeErr != nil {
+			return false, describeErr
+		}
+		for _, mt := range response.MountTargets {
+			if *mt.LifeCycleState != awsefs.LifeCycleStateDeleted {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	return err
+}
+
+func (efs *EFS) deleteEFSFileSystem(efsID string) error {
+	_, err := efs.efsClient.DeleteFileSystem(&awsefs.DeleteFileSystemInput{
+		FileSystemId: aws.String(efsID),
+	})
+	if err != nil {
+		if isAWSErrorCode(err, awsefs.ErrCodeFileSystemNotFound) {
+			klog.V(4).Infof("FileSystem %s already removed", efsID)
+			return nil
+		}
+		return fmt.Errorf("error deleting filesystem %s: %v", efsID, err)
+	}
+	log("deleted filesystem %s", efsID)
+	return nil
+}
+
+// deleteSecurityGroup deletes the security group, retrying while network
+// interfaces of the just deleted mount targets still reference it.
+func (efs *EFS) deleteSecurityGroup(sgID string) error {
+	input := &ec2.DeleteSecurityGroupInput{GroupId: aws.String(sgID)}
+	var lastErr error
+	err := wait.ExponentialBackoff(efs.backoff, func() (bool, error) {
+		_, lastErr = efs.fsAccountClient.DeleteSecurityGroup(input)
+		if lastErr == nil {
+			return true, nil
+		}
+		if isAWSErrorCode(lastErr, dependencyViolationErrorCode) {
+			klog.V(4).Infof("SecurityGroup %s is still in use: %v", sgID, lastErr)
+			return false, nil
+		}
+		return false, lastErr
+	})
+	if err != nil {
+		if lastErr != nil {
+			err = lastErr
+		}
+		return fmt.Errorf("error deleting security group %s: %v", sgID, err)
+	}
+	log("deleted security group %s", sgID)
+	return nil
+}
+
+func isAWSErrorCode(err error, code string) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == code
+	}
+	return false
+}
@@ -1,8 +1,8 @@
 package efscreate
 
 import (
+	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"k8s.io/klog/v2"
@@ -10,12 +10,18 @@ import (
 	v1 "github.com/openshift/api/config/v1"
 	corev1 "k8s.io/api/core/v1"
 
+	awsv2 "github.com/aws/aws-sdk-go-v2/aws"
+	efsv2 "github.com/aws/aws-sdk-go-v2/service/efs"
+	efstypes "github.com/aws/aws-sdk-go-v2/service/efs/types"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	awsefs "github.com/aws/aws-sdk-go/service/efs"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/openshift/aws-efs-csi-driver-operator/pkg/awsutil"
 )
 
 const (
@@ -32,13 +38,36 @@ const (
 )
 
 type EFS struct {
-	infra     *v1.Infrastructure
-	client    *ec2.EC2
-	efsClient *awsefs.EFS
-	vpcID     string
+	infra *v1.Infrastructure
+	// client is the EC2 client of the cluster account
+	client ec2API
+	// fsAccountClient is the EC2 client of the account of the file system, its
+	// security group and mount targets. It's the same as client unless in the
+	// cross-account mode.
+	fsAccountClient ec2API
+	efsClient       efsAPI
+	// mountTargetClient creates mount targets in the account of the file system
+	mountTargetClient mountTargetAPI
+	options           Options
+	// backoff of all waiters
+	backoff wait.Backoff
+	// vpcID is the VPC of the security group and mount targets
+	vpcID string
+	// cidrBlock is the primary CIDR block of the cluster VPC
 	cidrBlock string
-	subnetIDs []string
-	resources *ResourceInfo
+	// ipv4CIDRBlocks and ipv6CIDRBlocks are all CIDR blocks associated with the cluster VPC
+	ipv4CIDRBlocks []string
+	ipv6CIDRBlocks []string
+	// nodeSecurityGroupIDs are security groups of the cluster instances
+	nodeSecurityGroupIDs []string
+	subnetIDs            []string
+	// subnetZones maps subnet IDs to their availability zones
+	subnetZones map[string]string
+	// subnetIPAddressTypes maps subnet IDs to IP address types of their mount targets
+	subnetIPAddressTypes map[string]string
+	resources            *ResourceInfo
+	undoActions          []undoAction
+	steps                []StepReport
 }
 
 // store resources that the code created
@@ -46,61 +75,174 @@ type ResourceInfo struct {
 	securityGroupID string
 	efsID           string
 	mountTargets    []string
+	accessPoints    []string
 }
 
-func NewEFSSession(infra *v1.Infrastructure, sess *session.Session) *EFS {
+func NewEFSSession(infra *v1.Infrastructure, sess *session.Session, options Options) *EFS {
 	service := ec2.New(sess)
 	efsClient := awsefs.New(sess)
 	return &EFS{
-		client:    service,
-		efsClient: efsClient,
-		infra:     infra,
-		subnetIDs: []string{},
-		resources: &ResourceInfo{},
+		client:            service,
+		fsAccountClient:   service,
+		efsClient:         efsClient,
+		mountTargetClient: newMountTargetClient(sess),
+		infra:             infra,
+		options:           options,
+		backoff: wait.Backoff{
+			Duration: volumeCreateInitialDelay,
+			Factor:   volumeCreateBackoffFactor,
+			Steps:    volumeCreateBackoffSteps,
+		},
+		subnetIDs:            []string{},
+		subnetZones:          map[string]string{},
+		subnetIPAddressTypes: map[string]string{},
+		resources:            &ResourceInfo{},
+	}
+}
+
+// NewCrossAccountEFSSession returns EFS that discovers the cluster with
+// clusterSess and creates the file system, its security group and mount
+// targets with fsSess in another account.
+func NewCrossAccountEFSSession(infra *v1.Infrastructure, clusterSess, fsSess *session.Session, options Options) *EFS {
+	efs := NewEFSSession(infra, clusterSess, options)
+	efs.fsAccountClient = ec2.New(fsSess)
+	efs.efsClient = awsefs.New(fsSess)
+	efs.mountTargetClient = newMountTargetClient(fsSess)
+	return efs
+}
+
+// newMountTargetClient returns AWS SDK v2 EFS client with the region,
+// credentials, HTTP client and endpoint of the given session. The HTTP client
+// carries the custom CA bundle loaded by the session, if any.
+func newMountTargetClient(sess *session.Session) *efsv2.Client {
+	creds := sess.Config.Credentials
+	var baseEndpoint *string
+	if endpoint := aws.StringValue(sess.Config.Endpoint); endpoint != "" {
+		baseEndpoint = awsv2.String(endpoints.AddScheme(endpoint, aws.BoolValue(sess.Config.DisableSSL)))
+	}
+	var httpClient efsv2.HTTPClient
+	if sess.Config.HTTPClient != nil {
+		httpClient = sess.Config.HTTPClient
 	}
+	return efsv2.New(efsv2.Options{
+		Region:       aws.StringValue(sess.Config.Region),
+		BaseEndpoint: baseEndpoint,
+		HTTPClient:   httpClient,
+		Credentials: awsv2.CredentialsProviderFunc(func(ctx context.Context) (awsv2.Credentials, error) {
+			value, err := creds.GetWithContext(ctx)
+			if err != nil {
+				return awsv2.Credentials{}, err
+			}
+			return awsv2.Credentials{
+				AccessKeyID:     value.AccessKeyID,
+				SecretAccessKey: value.SecretAccessKey,
+				SessionToken:    value.SessionToken,
+				Source:          value.ProviderName,
+			}, nil
+		}),
+	})
 }
 
+// CreateEFSVolume creates the EFS volume and all AWS resources it needs. When
+// it fails, resources created by this call are rolled back in reverse order,
+// unless Options.KeepOnFailure is set. A failed rollback is returned together
+// with the original error. No filesystem ID is returned on failure.
 func (efs *EFS) CreateEFSVolume(nodes *corev1.NodeList) (string, error) {
+	fsID, err := efs.createEFSVolume(nodes)
+	if err == nil {
+		efs.undoActions = nil
+		return fsID, nil
+	}
+	if efs.options.KeepOnFailure {
+		log("keeping resources created before the failure: %+v", *efs.resources)
+		return "", err
+	}
+	klog.V(4).Info("Rolling back created resources")
+	if rollbackErr := efs.rollback(); rollbackErr != nil {
+		return "", fmt.Errorf("%w; %w", err, rollbackErr)
+	}
+	return "", err
+}
+
+func (efs *EFS) createEFSVolume(nodes *corev1.NodeList) (string, error) {
 	instances := efs.getInstanceIDs(nodes)
 
 	klog.V(4).Info("Loading AWS VPC")
+	start := time.Now()
 	err := efs.getSecurityInfo(instances)
 	if err != nil {
 		return "", err
 	}
+	efs.recordStep("LoadVPC", start)
 
-	klog.V(4).Info("Creating SecurityGroup")
-	sgid, err := efs.createSecurityGroup()
+	klog.V(4).Info("Ensuring SecurityGroup")
+	start = time.Now()
+	sg, err := efs.ensureSecurityGroup()
 	if err != nil {
 		return "", err
 	}
-	efs.resources.securityGroupID = sgid
-
-	klog.V(4).Info("Adding firewall rule for NFS")
-	ok, err := efs.addFireWallRule()
-	if err != nil || !ok {
-		return "", fmt.Errorf("error adding firewall rule: %v", err)
+	efs.resources.securityGroupID = *sg.GroupId
+	efs.recordStep("EnsureSecurityGroup", start)
+
+	klog.V(4).Info("Ensuring firewall rule for NFS")
+	start = time.Now()
+	rule := efs.getMissingFireWallRule(sg)
+	if rule == nil {
+		log("firewall rule for NFS already exists in security group %s", *sg.GroupId)
+	} else {
+		ok, err := efs.addFireWallRule(rule)
+		if err != nil || !ok {
+			return "", fmt.Errorf("error adding firewall rule: %v", err)
+		}
 	}
+	efs.recordStep("EnsureFirewallRule", start)
 
-	klog.V(4).Info("Creating EFS volume")
-	fileSystemID, err := efs.createEFSFileSystem()
+	klog.V(4).Info("Ensuring EFS volume")
+	start = time.Now()
+	fileSystemID, err := efs.ensureEFSFileSystem()
 	if err != nil {
 		return "", err
 	}
 	efs.resources.efsID = fileSystemID
+	efs.recordStep("EnsureFileSystem", start)
+
+	if efs.options.CrossAccount.Enabled() {
+		klog.V(4).Info("Setting FileSystemPolicy")
+		start = time.Now()
+		err = efs.putFileSystemPolicy(fileSystemID)
+		if err != nil {
+			return fileSystemID, err
+		}
+		efs.recordStep("PutFileSystemPolicy", start)
+	}
 
-	klog.V(4).Info("Creating MountTargets")
-	mts, err := efs.createMountTargets()
+	klog.V(4).Info("Ensuring MountTargets")
+	start = time.Now()
+	mts, err := efs.ensureMountTargets()
 	if err != nil {
 		return "", err
 	}
 	efs.resources.mountTargets = mts
+	efs.recordStep("EnsureMountTargets", start)
 
 	klog.V(4).Info("Waiting for MountTargets to get available")
+	start = time.Now()
 	err = efs.waitForAvailableMountTarget()
 	if err != nil {
 		return fileSystemID, fmt.Errorf("waiting for mount targets to be available failed: %v", err)
 	}
+	efs.recordStep("WaitForMountTargets", start)
+
+	if len(efs.options.AccessPoints) > 0 {
+		klog.V(4).Info("Ensuring AccessPoints")
+		start = time.Now()
+		aps, err := efs.ensureAccessPoints()
+		if err != nil {
+			return fileSystemID, err
+		}
+		efs.resources.accessPoints = aps
+		efs.recordStep("EnsureAccessPoints", start)
+	}
 	log("successfully created file system %s", fileSystemID)
 	return fileSystemID, nil
 }
@@ -108,46 +250,84 @@ func (efs *EFS) CreateEFSVolume(nodes *corev1.NodeList) (string, error) {
 func (efs *EFS) getInstanceIDs(nodes *corev1.NodeList) []string {
 	nodeIDs := sets.NewString()
 	for _, node := range nodes.Items {
-		//get providerID of the form aws:///us-west-2a/i-0304804a704fefb7d
-		instanceString := node.Spec.ProviderID
-		instanceStringArray := strings.Split(instanceString, "/")
-		if len(instanceStringArray) > 0 {
-			nodeIDs.Insert(instanceStringArray[len(instanceStringArray)-1])
-		}
+		_, instanceID := awsutil.ParseProviderID(node.Spec.ProviderID)
+		nodeIDs.Insert(instanceID)
 	}
 	return nodeIDs.List()
 }
 
+// ensureSecurityGroup returns the security group created by a previous run
+// of the tool, creating it if it does not exist yet.
+func (efs *EFS) ensureSecurityGroup() (*ec2.SecurityGroup, error) {
+	sg, err := efs.findSecurityGroup()
+	if err != nil {
+		return nil, err
+	}
+	if sg != nil {
+		log("using existing security group %s", *sg.GroupId)
+		return sg, nil
+	}
+	sgid, err := efs.createSecurityGroup()
+	if err != nil {
+		return nil, err
+	}
+	log("created security group %s", sgid)
+	efs.registerUndo(fmt.Sprintf("security group %s", sgid), func() error {
+		return efs.deleteSecurityGroup(sgid)
+	})
+	return &ec2.SecurityGroup{GroupId: aws.String(sgid)}, nil
+}
+
+func (efs *EFS) findSecurityGroup() (*ec2.SecurityGroup, error) {
+	input := &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("group-name"),
+				Values: []*string{aws.String(efs.getSecurityGroupName())},
+			},
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(efs.vpcID)},
+			},
+		},
+	}
+	response, err := efs.fsAccountClient.DescribeSecurityGroups(input)
+	if err != nil {
+		return nil, fmt.Errorf("error listing security groups: %v", err)
+	}
+	if len(response.SecurityGroups) < 1 {
+		return nil, nil
+	}
+	return response.SecurityGroups[0], nil
+}
+
 func (efs *EFS) createSecurityGroup() (string, error) {
-	infraID := efs.infra.Status.InfrastructureName
-	groupName := fmt.Sprintf(securityGroupNameFormat, infraID)
+	groupName := efs.getSecurityGroupName()
 	securityGroupInput := ec2.CreateSecurityGroupInput{
 		Description:       aws.String("for testing efs driver"),
 		GroupName:         aws.String(groupName),
 		VpcId:             &efs.vpcID,
 		TagSpecifications: efs.getTags(ec2.ResourceTypeSecurityGroup, groupName),
 	}
-	response, err := efs.client.CreateSecurityGroup(&securityGroupInput)
+	response, err := efs.fsAccountClient.CreateSecurityGroup(&securityGroupInput)
 	if err != nil {
-		return "", fmt.Errorf("error creating security group")
+		return "", fmt.Errorf("error creating security group: %v", err)
 	}
 	return *response.GroupId, nil
 }
 
+func (efs *EFS) getSecurityGroupName() string {
+	return fmt.Sprintf(securityGroupNameFormat, efs.infra.Status.InfrastructureName)
+}
+
+func (efs *EFS) getVolumeName() string {
+	return fmt.Sprintf(efsVolumeNameFormat, efs.infra.Status.InfrastructureName)
+}
+
 func (efs *EFS) getTags(resourceType string, resourceName string) []*ec2.TagSpecification {
-	var tagList []*ec2.Tag
-	tags := map[string]string{
-		"Name":                 resourceName,
-		efs.getClusterTagKey(): "owned",
-	}
-	for k, v := range tags {
-		tagList = append(tagList, &ec2.Tag{
-			Key: aws.String(k), Value: aws.String(v),
-		})
-	}
 	return []*ec2.TagSpecification{
 		{
-			Tags:         tagList,
+			Tags:         efs.getEC2Tags(resourceName),
 			ResourceType: aws.String(resourceType),
 		},
 	}
@@ -157,46 +337,124 @@ func (efs *EFS) getClusterTagKey() string {
 	return fmt.Sprintf(tagFormat, efs.infra.Status.InfrastructureName)
 }
 
-func (efs *EFS) addFireWallRule() (bool, error) {
-	ruleInput := ec2.AuthorizeSecurityGroupIngressInput{
-		CidrIp:     aws.String(efs.cidrBlock),
-		GroupId:    aws.String(efs.resources.securityGroupID),
-		IpProtocol: aws.String("tcp"),
-		ToPort:     aws.Int64(2049),
-		FromPort:   aws.Int64(2049),
+func log(msg string, args ...interface{}) {
+	klog.Infof(msg, args...)
+}
+
+// ensureEFSFileSystem returns the ID of the file system created by a previous
+// run of the tool, creating a new one if it does not exist yet.
+func (efs *EFS) ensureEFSFileSystem() (string, error) {
+	fs, err := efs.findEFSFileSystem()
+	if err != nil {
+		return "", err
 	}
-	response, err := efs.client.AuthorizeSecurityGroupIngress(&ruleInput)
+
+	var fileSystemID string
+	if fs == nil {
+		fileSystemID, err = efs.createEFSFileSystem()
+		if err != nil {
+			return fileSystemID, err
+		}
+	} else {
+		fileSystemID = *fs.FileSystemId
+		if aws.StringValue(fs.AvailabilityZoneName) != efs.options.OneZone {
+			return "", fmt.Errorf("existing filesystem %s has availability zone %q, expected %q", fileSystemID, aws.StringValue(fs.AvailabilityZoneName), efs.options.OneZone)
+		}
+		log("using existing filesystem %s", fileSystemID)
+		err = efs.waitForEFSToBeAvailable(fileSystemID)
+		if err != nil {
+			return fileSystemID, fmt.Errorf("waiting for EFS filesystem to become available failed: %v", err)
+		}
+	}
+
+	err = efs.putLifecyclePolicies(fileSystemID)
 	if err != nil {
-		return false, fmt.Errorf("error creating firewall rule: %v", err)
+		return fileSystemID, err
 	}
-	return *response.Return, nil
+	return fileSystemID, nil
 }
 
-func log(msg string, args ...interface{}) {
-	klog.Infof(msg, args...)
+// findEFSFileSystem looks up the file system owned by the cluster, first by its
+// creation token and then by its Name tag, so that file systems created by older
+// versions of the tool without a creation token are found too.
+func (efs *EFS) findEFSFileSystem() (*awsefs.FileSystemDescription, error) {
+	volumeName := efs.getVolumeName()
+	response, err := efs.efsClient.DescribeFileSystems(&awsefs.DescribeFileSystemsInput{
+		CreationToken: aws.String(volumeName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing filesystems: %v", err)
+	}
+	if len(response.FileSystems) > 0 {
+		fs := response.FileSystems[0]
+		if !efs.isClusterOwned(fs.Tags) {
+			return nil, fmt.Errorf("filesystem %s with creation token %s is not owned by the cluster", *fs.FileSystemId, volumeName)
+		}
+		return fs, nil
+	}
+
+	input := &awsefs.DescribeFileSystemsInput{}
+	for {
+		response, err := efs.efsClient.DescribeFileSystems(input)
+		if err != nil {
+			return nil, fmt.Errorf("error listing filesystems: %v", err)
+		}
+		for _, fs := range response.FileSystems {
+			if aws.StringValue(fs.Name) == volumeName && efs.isClusterOwned(fs.Tags) {
+				return fs, nil
+			}
+		}
+		if response.NextMarker == nil || len(*response.NextMarker) == 0 {
+			return nil, nil
+		}
+		input.Marker = response.NextMarker
+	}
+}
+
+func (efs *EFS) isClusterOwned(tags []*awsefs.Tag) bool {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == efs.getClusterTagKey() && aws.StringValue(tag.Value) == "owned" {
+			return true
+		}
+	}
+	return false
 }
 
 func (efs *EFS) createEFSFileSystem() (string, error) {
-	volumeName := fmt.Sprintf(efsVolumeNameFormat, efs.infra.Status.InfrastructureName)
+	volumeName := efs.getVolumeName()
+	fsOptions := efs.options.FileSystem
 	input := &awsefs.CreateFileSystemInput{
+		CreationToken:   aws.String(volumeName),
 		Encrypted:       aws.Bool(true),
-		PerformanceMode: aws.String(awsefs.PerformanceModeGeneralPurpose),
-		Tags: []*awsefs.Tag{
-			{
-				Key:   aws.String("Name"),
-				Value: aws.String(volumeName),
-			},
-			{
-				Key:   aws.String(efs.getClusterTagKey()),
-				Value: aws.String("owned"),
-			},
-		},
+		PerformanceMode: aws.String(fsOptions.PerformanceMode),
+		Backup:          aws.Bool(fsOptions.Backup),
+		Tags:            efs.getEFSTags(volumeName),
+	}
+	if fsOptions.ThroughputMode != "" {
+		input.ThroughputMode = aws.String(fsOptions.ThroughputMode)
+	}
+	if fsOptions.ThroughputMode == awsefs.ThroughputModeProvisioned {
+		input.ProvisionedThroughputInMibps = aws.Float64(fsOptions.ProvisionedThroughputInMibps)
+	}
+	if fsOptions.KMSKeyID != "" {
+		input.KmsKeyId = aws.String(fsOptions.KMSKeyID)
+	}
+	if efs.options.OneZone != "" {
+		input.AvailabilityZoneName = aws.String(efs.options.OneZone)
 	}
 	response, err := efs.efsClient.CreateFileSystem(input)
 	if err != nil {
 		log("error creating filesystem: %v", err)
 		return "", fmt.Errorf("error creating filesystem: %v", err)
 	}
+	log("created filesystem %s", *response.FileSystemId)
+	efs.registerUndo(fmt.Sprintf("filesystem %s", *response.FileSystemId), func() error {
+		// Mount targets are rolled back before the filesystem, wait for them to disappear
+		if err := efs.waitForDeletedMountTargets(*response.FileSystemId); err != nil {
+			return fmt.Errorf("waiting for mount targets to be deleted failed: %v", err)
+		}
+		return efs.deleteEFSFileSystem(*response.FileSystemId)
+	})
 	err = efs.waitForEFSToBeAvailable(*response.FileSystemId)
 	if err != nil {
 		log("error waiting for filesystem to become available: %v", err)
@@ -205,33 +463,107 @@ func (efs *EFS) createEFSFileSystem() (string, error) {
 	return *response.FileSystemId, nil
 }
 
-func (efs *EFS) createMountTargets() ([]string, error) {
+// putLifecyclePolicies configures transitions to IA and archive storage
+// classes, when requested.
+func (efs *EFS) putLifecyclePolicies(efsID string) error {
+	fsOptions := efs.options.FileSystem
+	var policies []*awsefs.LifecyclePolicy
+	if fsOptions.TransitionToIA != "" {
+		policies = append(policies, &awsefs.LifecyclePolicy{TransitionToIA: aws.String(fsOptions.TransitionToIA)})
+	}
+	if fsOptions.TransitionToArchive != "" {
+		policies = append(policies, &awsefs.LifecyclePolicy{TransitionToArchive: aws.String(fsOptions.TransitionToArchive)})
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+
+	_, err := efs.efsClient.PutLifecycleConfiguration(&awsefs.PutLifecycleConfigurationInput{
+		FileSystemId:      aws.String(efsID),
+		LifecyclePolicies: policies,
+	})
+	if err != nil {
+		return fmt.Errorf("error setting lifecycle policies of filesystem %s: %v", efsID, err)
+	}
+	log("set lifecycle policies of filesystem %s", efsID)
+	return nil
+}
+
+// ensureMountTargets creates a mount target in every subnet that does not
+// have one for the file system yet and returns IDs of all mount targets.
+func (efs *EFS) ensureMountTargets() ([]string, error) {
+	existing, err := efs.getMountTargetsBySubnet()
+	if err != nil {
+		return nil, err
+	}
+
 	var mountTargets []string
 	for i := range efs.subnetIDs {
 		subnet := efs.subnetIDs[i]
-		mountTargetInput := &awsefs.CreateMountTargetInput{
-			FileSystemId:   aws.String(efs.resources.efsID),
-			SecurityGroups: []*string{aws.String(efs.resources.securityGroupID)},
-			SubnetId:       aws.String(subnet),
+		if mtID, found := existing[subnet]; found {
+			log("using existing mount target %s in subnet %s", mtID, subnet)
+			mountTargets = append(mountTargets, mtID)
+			continue
 		}
-		mt, err := efs.efsClient.CreateMountTarget(mountTargetInput)
+		mtID, err := efs.createMountTarget(subnet)
 		if err != nil {
-			return mountTargets, fmt.Errorf("error creating mount target: %v", err)
+			return mountTargets, err
 		}
-		mountTargets = append(mountTargets, *mt.MountTargetId)
+		mountTargets = append(mountTargets, mtID)
 	}
 	return mountTargets, nil
 }
 
+func (efs *EFS) getMountTargetsBySubnet() (map[string]string, error) {
+	mountTargets := map[string]string{}
+	input := &awsefs.DescribeMountTargetsInput{FileSystemId: aws.String(efs.resources.efsID)}
+	for {
+		response, err := efs.efsClient.DescribeMountTargets(input)
+		if err != nil {
+			return nil, fmt.Errorf("error listing mount targets: %v", err)
+		}
+		for _, mt := range response.MountTargets {
+			if aws.StringValue(mt.LifeCycleState) == awsefs.LifeCycleStateDeleting ||
+				aws.StringValue(mt.LifeCycleState) == awsefs.LifeCycleStateDeleted {
+				continue
+			}
+			mountTargets[*mt.SubnetId] = *mt.MountTargetId
+		}
+		if response.NextMarker == nil || len(*response.NextMarker) == 0 {
+			return mountTargets, nil
+		}
+		input.Marker = response.NextMarker
+	}
+}
+
+func (efs *EFS) createMountTarget(subnet string) (string, error) {
+	mountTargetInput := &efsv2.CreateMountTargetInput{
+		FileSystemId:   aws.String(efs.resources.efsID),
+		SecurityGroups: []string{efs.resources.securityGroupID},
+		SubnetId:       aws.String(subnet),
+	}
+	ipAddressType := efs.subnetIPAddressTypes[subnet]
+	if ipAddressType != "" {
+		mountTargetInput.IpAddressType = efstypes.IpAddressType(ipAddressType)
+	}
+	mt, err := efs.mountTargetClient.CreateMountTarget(context.TODO(), mountTargetInput)
+	if err != nil {
+		return "", fmt.Errorf("error creating mount target: %v", err)
+	}
+	log("created mount target %s in subnet %s with IP address type %s", *mt.MountTargetId, subnet, ipAddressType)
+	efs.registerUndo(fmt.Sprintf("mount target %s", *mt.MountTargetId), func() error {
+		return efs.deleteMountTarget(*mt.MountTargetId)
+	})
+	if err := efs.tagMountTarget(mt.MountTargetId, mt.NetworkInterfaceId); err != nil {
+		return "", err
+	}
+	return *mt.MountTargetId, nil
+}
+
 func (efs *EFS) waitForAvailableMountTarget() error {
 	efsID := efs.resources.efsID
 	describeInput := &awsefs.DescribeMountTargetsInput{FileSystemId: aws.String(efsID)}
-	backoff := wait.Backoff{
-		Duration: volumeCreateInitialDelay,
-		Factor:   operationBackoffFactor,
-		Steps:    volumeCreateBackoffSteps,
-	}
-	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+	err := wait.ExponentialBackoff(efs.backoff, func() (bool, error) {
 		response, describeErr := efs.efsClient.DescribeMountTargets(describeInput)
 		if describeErr != nil {
 			return false, describeErr
@@ -242,6 +574,10 @@ func (efs *EFS) waitForAvailableMountTarget() error {
 		}
 		allReady := true
 		for _, mt := range mountTargets {
+			if *mt.LifeCycleState == awsefs.LifeCycleStateDeleting || *mt.LifeCycleState == awsefs.LifeCycleStateDeleted {
+				// Leftovers of a previous run, replacements were created by ensureMountTargets
+				continue
+			}
 			if *mt.LifeCycleState != awsefs.LifeCycleStateAvailable {
 				allReady = false
 			}
@@ -256,12 +592,7 @@ func (efs *EFS) waitForAvailableMountTarget() error {
 
 func (efs *EFS) waitForEFSToBeAvailable(efsID string) error {
 	describeInput := &awsefs.DescribeFileSystemsInput{FileSystemId: aws.String(efsID)}
-	backoff := wait.Backoff{
-		Duration: volumeCreateInitialDelay,
-		Factor:   volumeCreateBackoffFactor,
-		Steps:    volumeCreateBackoffSteps,
-	}
-	err := wait.ExponentialBackoff(backoff, func() (done bool, err error) {
+	err := wait.ExponentialBackoff(efs.backoff, func() (done bool, err error) {
 		response, err := efs.efsClient.DescribeFileSystems(describeInput)
 		if err != nil {
 			return false, err
@@ -279,7 +610,8 @@ func (efs *EFS) waitForEFSToBeAvailable(efsID string) error {
 	return err
 }
 
-func (efs *EFS) getSecurityInfo(instances []string) error {
+// describeInstances returns the EC2 instances with the given IDs.
+func (efs *EFS) describeInstances(instances []string) ([]*ec2.Instance, error) {
 	var instancePointers []*string
 	for i := range instances {
 		instancePointers = append(instancePointers, &instances[i])
@@ -293,7 +625,7 @@ func (efs *EFS) getSecurityInfo(instances []string) error {
 	for {
 		response, err := efs.client.DescribeInstances(request)
 		if err != nil {
-			return fmt.Errorf("error listing AWS instances: %v", err)
+			return nil, fmt.Errorf("error listing AWS instances: %v", err)
 		}
 
 		for _, reservation := range response.Reservations {
@@ -307,7 +639,30 @@ func (efs *EFS) getSecurityInfo(instances []string) error {
 		request.NextToken = nextToken
 	}
 	if len(results) < 1 {
-		return fmt.Errorf("no matching instances found")
+		return nil, fmt.Errorf("no matching instances found")
+	}
+	return results, nil
+}
+
+// getVPCID finds the VPC of the security group and mount targets without
+// loading the rest of the cluster network information.
+func (efs *EFS) getVPCID(instances []string) error {
+	if efs.options.CrossAccount.Enabled() {
+		efs.vpcID = efs.options.CrossAccount.VPCID
+		return nil
+	}
+	results, err := efs.describeInstances(instances)
+	if err != nil {
+		return err
+	}
+	efs.vpcID = *results[0].VpcId
+	return nil
+}
+
+func (efs *EFS) getSecurityInfo(instances []string) error {
+	results, err := efs.describeInstances(instances)
+	if err != nil {
+		return err
 	}
 	instance := results[0]
 	efs.vpcID = *instance.VpcId
@@ -323,11 +678,45 @@ func (efs *EFS) getSecurityInfo(instances []string) error {
 	}
 	clusterVPC := clusterVPCs[0]
 	efs.cidrBlock = *clusterVPC.CidrBlock
+	efs.ipv4CIDRBlocks, efs.ipv6CIDRBlocks = getVPCCIDRBlocks(clusterVPC)
 
-	subNetSet := sets.NewString()
+	securityGroupSet := sets.NewString()
 	for i := range results {
-		subNetSet.Insert(*results[i].SubnetId)
+		for _, group := range results[i].SecurityGroups {
+			securityGroupSet.Insert(*group.GroupId)
+		}
+	}
+	efs.nodeSecurityGroupIDs = securityGroupSet.List()
+	if efs.options.IngressSource == IngressSourceNodeSecurityGroups && len(efs.nodeSecurityGroupIDs) == 0 {
+		return fmt.Errorf("no security groups found on cluster instances")
+	}
+
+	if efs.options.CrossAccount.Enabled() {
+		// The security group and mount targets are created in the VPC of the
+		// filesystem account, NFS is allowed from the cluster VPC
+		efs.vpcID = efs.options.CrossAccount.VPCID
+		if err := efs.selectCrossAccountSubnets(results); err != nil {
+			return err
+		}
+	} else if err := efs.selectSubnets(results); err != nil {
+		return err
+	}
+
+	if zone := efs.options.OneZone; zone != "" {
+		if err := efs.selectOneZoneSubnet(zone); err != nil {
+			return err
+		}
+	}
+
+	if efs.options.IPAddressType == IPAddressTypeAuto {
+		efs.subnetIPAddressTypes, err = efs.getSubnetIPAddressTypes(efs.subnetIDs)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, subnet := range efs.subnetIDs {
+			efs.subnetIPAddressTypes[subnet] = efs.options.IPAddressType
+		}
 	}
-	efs.subnetIDs = subNetSet.List()
 	return nil
 }
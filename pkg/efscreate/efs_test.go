@@ -0,0 +1,560 @@
+package efscreate
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	efsv2 "github.com/aws/aws-sdk-go-v2/service/efs"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+	v1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	testInfraName = "test-abcde"
+	testVPCID     = "vpc-1"
+)
+
+// newTestCluster returns a fake with three private subnets in three zones
+// and nodes in the first two of them.
+func newTestCluster() *fakeAWS {
+	clusterTag := "kubernetes.io/cluster/" + testInfraName
+	return newFakeAWS().
+		withVPC(testVPCID, "10.0.0.0/16").
+		withSubnet(testVPCID, "subnet-a", "us-east-1a", clusterTag, internalELBTagKey).
+		withSubnet(testVPCID, "subnet-b", "us-east-1b", clusterTag, internalELBTagKey).
+		withSubnet(testVPCID, "subnet-c", "us-east-1c", clusterTag, internalELBTagKey).
+		withSubnet(testVPCID, "subnet-public", "us-east-1a", clusterTag).
+		withInstance("i-1", "subnet-a", "sg-node").
+		withInstance("i-2", "subnet-b", "sg-node").
+		withInstance("i-3", "subnet-a", "sg-node")
+}
+
+func newTestOptions() Options {
+	return Options{
+		IngressSource: IngressSourceVPCCIDR,
+		IPAddressType: IPAddressTypeIPv4Only,
+		FileSystem: FileSystemOptions{
+			PerformanceMode: awsefs.PerformanceModeGeneralPurpose,
+		},
+	}
+}
+
+func newTestEFS(fake *fakeAWS, options Options) *EFS {
+	infra := &v1.Infrastructure{
+		Status: v1.InfrastructureStatus{
+			InfrastructureName: testInfraName,
+			PlatformStatus: &v1.PlatformStatus{
+				Type: v1.AWSPlatformType,
+				AWS:  &v1.AWSPlatformStatus{Region: "us-east-1"},
+			},
+		},
+	}
+	return &EFS{
+		infra:             infra,
+		client:            fake,
+		fsAccountClient:   fake,
+		efsClient:         fake,
+		mountTargetClient: fake,
+		options:           options,
+		backoff: wait.Backoff{
+			Duration: time.Millisecond,
+			Factor:   1,
+			Steps:    5,
+		},
+		subnetIDs:            []string{},
+		subnetZones:          map[string]string{},
+		subnetIPAddressTypes: map[string]string{},
+		resources:            &ResourceInfo{},
+	}
+}
+
+func newTestNodes(instanceIDs ...string) *corev1.NodeList {
+	nodes := &corev1.NodeList{}
+	for _, id := range instanceIDs {
+		nodes.Items = append(nodes.Items, corev1.Node{
+			Spec: corev1.NodeSpec{ProviderID: "aws:///us-east-1a/" + id},
+		})
+	}
+	return nodes
+}
+
+// countLive returns the number of resources that are not being deleted.
+func countLive[T any](resources map[string]T, state func(T) *string) int {
+	count := 0
+	for _, resource := range resources {
+		switch aws.StringValue(state(resource)) {
+		case awsefs.LifeCycleStateDeleting, awsefs.LifeCycleStateDeleted:
+		default:
+			count++
+		}
+	}
+	return count
+}
+
+func liveFileSystems(f *fakeAWS) int {
+	return countLive(f.fileSystems, func(fs *awsefs.FileSystemDescription) *string { return fs.LifeCycleState })
+}
+
+func liveMountTargets(f *fakeAWS) int {
+	return countLive(f.mountTargets, func(mt *awsefs.MountTargetDescription) *string { return mt.LifeCycleState })
+}
+
+func liveAccessPoints(f *fakeAWS) int {
+	return countLive(f.accessPoints, func(ap *awsefs.AccessPointDescription) *string { return ap.LifeCycleState })
+}
+
+func checkError(t *testing.T, err error, expectedErr string) {
+	t.Helper()
+	if expectedErr == "" && err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expectedErr != "" && (err == nil || !strings.Contains(err.Error(), expectedErr)) {
+		t.Fatalf("expected error containing %q, got %v", expectedErr, err)
+	}
+}
+
+func TestGetInstanceIDs(t *testing.T) {
+	tests := []struct {
+		name        string
+		providerIDs []string
+		expected    []string
+	}{
+		{
+			name:        "sorted and deduplicated",
+			providerIDs: []string{"aws:///us-east-1b/i-2", "aws:///us-east-1a/i-1", "aws:///us-east-1b/i-2"},
+			expected:    []string{"i-1", "i-2"},
+		},
+		{
+			name:        "no nodes",
+			providerIDs: nil,
+			expected:    []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodes := &corev1.NodeList{}
+			for _, id := range test.providerIDs {
+				nodes.Items = append(nodes.Items, corev1.Node{Spec: corev1.NodeSpec{ProviderID: id}})
+			}
+			efs := newTestEFS(newFakeAWS(), newTestOptions())
+			ids := efs.getInstanceIDs(nodes)
+			if strings.Join(ids, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected %v, got %v", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestGetSecurityInfo(t *testing.T) {
+	tests := []struct {
+		name            string
+		fake            func() *fakeAWS
+		options         func(*Options)
+		instances       []string
+		expectedSubnets []string
+		expectedErr     string
+	}{
+		{
+			name: "instances on multiple pages",
+			fake: func() *fakeAWS {
+				f := newTestCluster()
+				f.instancesPage = 1
+				return f
+			},
+			instances:       []string{"i-1", "i-2", "i-3"},
+			expectedSubnets: []string{"subnet-a", "subnet-b", "subnet-c"},
+		},
+		{
+			name: "multiple instance subnets in a zone",
+			fake: func() *fakeAWS {
+				return newTestCluster().
+					withSubnet(testVPCID, "subnet-a2", "us-east-1a").
+					withInstance("i-4", "subnet-a2")
+			},
+			instances:   []string{"i-1", "i-4"},
+			expectedErr: "only one mount target per availability zone",
+		},
+		{
+			name:            "explicit subnets",
+			fake:            newTestCluster,
+			options:         func(o *Options) { o.SubnetIDs = []string{"subnet-b"} },
+			instances:       []string{"i-1"},
+			expectedSubnets: []string{"subnet-b"},
+		},
+		{
+			name: "explicit subnet from another VPC",
+			fake: func() *fakeAWS {
+				return newTestCluster().withSubnet("vpc-2", "subnet-x", "us-east-1a")
+			},
+			options:     func(o *Options) { o.SubnetIDs = []string{"subnet-x"} },
+			instances:   []string{"i-1"},
+			expectedErr: "is not in the VPC",
+		},
+		{
+			name:            "one zone",
+			fake:            newTestCluster,
+			options:         func(o *Options) { o.OneZone = "us-east-1c" },
+			instances:       []string{"i-1"},
+			expectedSubnets: []string{"subnet-c"},
+		},
+		{
+			name:        "no instances",
+			fake:        newTestCluster,
+			instances:   []string{"i-unknown"},
+			expectedErr: "no matching instances found",
+		},
+		{
+			name: "DescribeInstances error",
+			fake: func() *fakeAWS {
+				return newTestCluster().failOn("DescribeInstances", 0, errors.New("throttled"))
+			},
+			instances:   []string{"i-1"},
+			expectedErr: "error listing AWS instances: throttled",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := newTestOptions()
+			if test.options != nil {
+				test.options(&options)
+			}
+			efs := newTestEFS(test.fake(), options)
+			err := efs.getSecurityInfo(test.instances)
+			checkError(t, err, test.expectedErr)
+			if err != nil {
+				return
+			}
+			if efs.vpcID != testVPCID || efs.cidrBlock != "10.0.0.0/16" {
+				t.Errorf("unexpected VPC %s with CIDR %s", efs.vpcID, efs.cidrBlock)
+			}
+			if strings.Join(efs.nodeSecurityGroupIDs, ",") != "sg-node" {
+				t.Errorf("expected node security group sg-node, got %v", efs.nodeSecurityGroupIDs)
+			}
+			if strings.Join(efs.subnetIDs, ",") != strings.Join(test.expectedSubnets, ",") {
+				t.Errorf("expected subnets %v, got %v", test.expectedSubnets, efs.subnetIDs)
+			}
+		})
+	}
+}
+
+func TestCreateEFSVolume(t *testing.T) {
+	tests := []struct {
+		name    string
+		fake    func() *fakeAWS
+		options func(*Options)
+		// expected error and resources left after CreateEFSVolume
+		expectedErr            string
+		expectedFileSystems    int
+		expectedMountTargets   int
+		expectedAccessPoints   int
+		expectedSecurityGroups int
+	}{
+		{
+			name:                   "new volume",
+			fake:                   newTestCluster,
+			expectedFileSystems:    1,
+			expectedMountTargets:   3,
+			expectedSecurityGroups: 1,
+		},
+		{
+			name: "new volume with access points",
+			fake: newTestCluster,
+			options: func(o *Options) {
+				o.AccessPoints = []AccessPointOptions{
+					{UID: 1000, GID: 1000, Path: "/data", Permissions: "750"},
+					{UID: 0, GID: 0, Path: "/root", Permissions: "700"},
+				}
+			},
+			expectedFileSystems:    1,
+			expectedMountTargets:   3,
+			expectedAccessPoints:   2,
+			expectedSecurityGroups: 1,
+		},
+		{
+			name: "mount target failure rolls back",
+			fake: func() *fakeAWS {
+				return newTestCluster().failOn("CreateMountTarget", 1, errors.New("limit exceeded"))
+			},
+			expectedErr: "error creating mount target: limit exceeded",
+		},
+		{
+			name: "mount target failure keeps resources",
+			fake: func() *fakeAWS {
+				return newTestCluster().failOn("CreateMountTarget", 1, errors.New("limit exceeded"))
+			},
+			options:                func(o *Options) { o.KeepOnFailure = true },
+			expectedErr:            "error creating mount target: limit exceeded",
+			expectedFileSystems:    1,
+			expectedMountTargets:   1,
+			expectedSecurityGroups: 1,
+		},
+		{
+			name: "filesystem never available",
+			fake: func() *fakeAWS {
+				f := newTestCluster()
+				f.availableAfter = 100
+				return f
+			},
+			expectedErr: "waiting for EFS filesystem to become available failed",
+		},
+		{
+			name: "access point failure rolls back",
+			fake: func() *fakeAWS {
+				return newTestCluster().failOn("CreateAccessPoint", 0, errors.New("access denied"))
+			},
+			options: func(o *Options) {
+				o.AccessPoints = []AccessPointOptions{{UID: 1000, GID: 1000, Path: "/data", Permissions: "750"}}
+			},
+			expectedErr: "error creating access point for /data: access denied",
+		},
+		{
+			name: "rollback failure is reported",
+			fake: func() *fakeAWS {
+				return newTestCluster().
+					failOn("CreateMountTarget", 1, errors.New("limit exceeded")).
+					failOn("DeleteFileSystem", 0, errors.New("throttled"))
+			},
+			expectedErr:         "error creating mount target: limit exceeded; failed to roll back: [filesystem",
+			expectedFileSystems: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := test.fake()
+			options := newTestOptions()
+			if test.options != nil {
+				test.options(&options)
+			}
+			efs := newTestEFS(fake, options)
+			fsID, err := efs.CreateEFSVolume(newTestNodes("i-1", "i-2", "i-3"))
+			checkError(t, err, test.expectedErr)
+			if err != nil && fsID != "" {
+				t.Errorf("expected no filesystem ID on error, got %s", fsID)
+			}
+
+			if n := liveFileSystems(fake); n != test.expectedFileSystems {
+				t.Errorf("expected %d filesystems, got %d", test.expectedFileSystems, n)
+			}
+			if n := liveMountTargets(fake); n != test.expectedMountTargets {
+				t.Errorf("expected %d mount targets, got %d", test.expectedMountTargets, n)
+			}
+			if n := liveAccessPoints(fake); n != test.expectedAccessPoints {
+				t.Errorf("expected %d access points, got %d", test.expectedAccessPoints, n)
+			}
+			if n := len(fake.securityGroups); n != test.expectedSecurityGroups {
+				t.Errorf("expected %d security groups, got %d", test.expectedSecurityGroups, n)
+			}
+		})
+	}
+}
+
+func TestCreateEFSVolumeAdoptsExistingResources(t *testing.T) {
+	fake := newTestCluster()
+	nodes := newTestNodes("i-1", "i-2", "i-3")
+
+	firstID, err := newTestEFS(fake, newTestOptions()).CreateEFSVolume(nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	efs := newTestEFS(fake, newTestOptions())
+	secondID, err := efs.CreateEFSVolume(nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if firstID != secondID {
+		t.Errorf("expected the second run to adopt filesystem %s, got %s", firstID, secondID)
+	}
+	for operation, expected := range map[string]int{
+		"CreateSecurityGroup":           1,
+		"AuthorizeSecurityGroupIngress": 1,
+		"CreateFileSystem":              1,
+		"CreateMountTarget":             3,
+	} {
+		if fake.calls[operation] != expected {
+			t.Errorf("expected %d %s calls, got %d", expected, operation, fake.calls[operation])
+		}
+	}
+
+	report, err := efs.GetReport()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.FileSystemID != secondID || len(report.MountTargets) != 3 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestDeleteEFSVolume(t *testing.T) {
+	fake := newTestCluster()
+	options := newTestOptions()
+	options.AccessPoints = []AccessPointOptions{{UID: 1000, GID: 1000, Path: "/data", Permissions: "750"}}
+	if _, err := newTestEFS(fake, options).CreateEFSVolume(newTestNodes("i-1", "i-2")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A security group with the same name and tag in a different VPC does
+	// not belong to this cluster
+	efs := newTestEFS(fake, newTestOptions())
+	fake.securityGroups["sg-other-vpc"] = &ec2.SecurityGroup{
+		GroupId:   aws.String("sg-other-vpc"),
+		GroupName: aws.String(efs.getSecurityGroupName()),
+		VpcId:     aws.String("vpc-2"),
+		Tags:      []*ec2.Tag{{Key: aws.String(efs.getClusterTagKey()), Value: aws.String("owned")}},
+	}
+
+	if err := efs.DeleteEFSVolume(newTestNodes("i-1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if liveFileSystems(fake) != 0 || liveMountTargets(fake) != 0 || liveAccessPoints(fake) != 0 || len(fake.securityGroups) != 1 {
+		t.Errorf("expected all resources to be deleted, got filesystems %d, mount targets %d, access points %d, security groups %d",
+			liveFileSystems(fake), liveMountTargets(fake), liveAccessPoints(fake), len(fake.securityGroups))
+	}
+	if _, found := fake.securityGroups["sg-other-vpc"]; !found {
+		t.Errorf("expected security group in a different VPC to be kept")
+	}
+
+	// Nothing to delete is not an error
+	if err := newTestEFS(fake, newTestOptions()).DeleteEFSVolume(newTestNodes("i-1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWaiters(t *testing.T) {
+	tests := []struct {
+		name           string
+		availableAfter int
+		failOn         string
+		expectedErr    string
+	}{
+		{
+			name:           "available after a few attempts",
+			availableAfter: 3,
+		},
+		{
+			name:           "timeout",
+			availableAfter: 100,
+			expectedErr:    wait.ErrWaitTimeout.Error(),
+		},
+		{
+			name:        "describe error",
+			failOn:      "Describe",
+			expectedErr: "service unavailable",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newTestCluster()
+			fake.availableAfter = test.availableAfter
+			fs, _ := fake.CreateFileSystem(&awsefs.CreateFileSystemInput{CreationToken: aws.String("token")})
+			fake.CreateMountTarget(context.TODO(), &efsv2.CreateMountTargetInput{
+				FileSystemId: fs.FileSystemId,
+				SubnetId:     aws.String("subnet-a"),
+			})
+			if test.failOn != "" {
+				fake.failOn(test.failOn+"FileSystems", 0, errors.New("service unavailable"))
+				fake.failOn(test.failOn+"MountTargets", 0, errors.New("service unavailable"))
+			}
+			efs := newTestEFS(fake, newTestOptions())
+			efs.resources.efsID = *fs.FileSystemId
+
+			checkError(t, efs.waitForEFSToBeAvailable(*fs.FileSystemId), test.expectedErr)
+			checkError(t, efs.waitForAvailableMountTarget(), test.expectedErr)
+		})
+	}
+}
+
+func TestParseAccessPoint(t *testing.T) {
+	tests := []struct {
+		spec        string
+		expected    AccessPointOptions
+		expectedErr string
+	}{
+		{
+			spec:     "1000:1000:/data",
+			expected: AccessPointOptions{UID: 1000, GID: 1000, Path: "/data", Permissions: "755"},
+		},
+		{
+			spec:     "0:100:/shared/dir:0770",
+			expected: AccessPointOptions{UID: 0, GID: 100, Path: "/shared/dir", Permissions: "0770"},
+		},
+		{
+			spec:        "1000:/data",
+			expectedErr: "expected <uid>:<gid>:<path>[:<permissions>]",
+		},
+		{
+			spec:        "-1:1000:/data",
+			expectedErr: "invalid uid",
+		},
+		{
+			spec:        "1000:1000:data",
+			expectedErr: "it must be absolute",
+		},
+		{
+			spec:        "1000:1000:/data:789",
+			expectedErr: "invalid permissions",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			ap, err := ParseAccessPoint(test.spec)
+			checkError(t, err, test.expectedErr)
+			if err == nil && ap != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, ap)
+			}
+		})
+	}
+}
+
+func TestNewMountTargetClient(t *testing.T) {
+	var requests []string
+	// The TLS server has a self-signed certificate, trusted only with a custom CA bundle
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"MountTargetId":"fsmt-1"}`))
+	}))
+	defer server.Close()
+
+	// The bundle from the environment would take precedence
+	t.Setenv("AWS_CA_BUNDLE", "")
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			Region:      aws.String("us-east-1"),
+			Endpoint:    aws.String(strings.TrimPrefix(server.URL, "https://")),
+			Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		},
+		CustomCABundle: bytes.NewReader(caBundle),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	output, err := newMountTargetClient(sess).CreateMountTarget(context.TODO(), &efsv2.CreateMountTargetInput{
+		FileSystemId: aws.String("fs-1"),
+		SubnetId:     aws.String("subnet-a"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if aws.StringValue(output.MountTargetId) != "fsmt-1" {
+		t.Errorf("expected mount target fsmt-1, got %s", aws.StringValue(output.MountTargetId))
+	}
+	if expected := []string{"POST /2015-02-01/mount-targets"}; !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests %v, got %v", expected, requests)
+	}
+}
@@ -0,0 +1,600 @@
+package efscreate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	efsv2 "github.com/aws/aws-sdk-go-v2/service/efs"
+	efstypes "github.com/aws/aws-sdk-go-v2/service/efs/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+)
+
+// injectedError makes an API call fail after the given number of successful calls.
+type injectedError struct {
+	after int
+	err   error
+}
+
+// fakeAWS is an in-memory implementation of ec2API, efsAPI and mountTargetAPI. File systems,
+// mount targets and access points are created in the "creating" state and
+// become "available" after availableAfter Describe calls. Deleted mount
+// targets and access points stay in the "deleting" state for one Describe call.
+type fakeAWS struct {
+	instances      []*ec2.Instance
+	instancesPage  int
+	vpcs           map[string]*ec2.Vpc
+	subnets        map[string]*ec2.Subnet
+	securityGroups map[string]*ec2.SecurityGroup
+	fileSystems    map[string]*awsefs.FileSystemDescription
+	mountTargets   map[string]*awsefs.MountTargetDescription
+	accessPoints   map[string]*awsefs.AccessPointDescription
+	lifecycle      map[string][]*awsefs.LifecyclePolicy
+	policies       map[string]string
+	// zoneIDs maps zone names to zone IDs, which differ between accounts
+	zoneIDs map[string]string
+	// Security groups of mount target network interfaces
+	mountTargetGroups map[string][]string
+	// IP address types requested for mount targets
+	mountTargetIPAddressTypes map[string]string
+	// Tags of mount target network interfaces
+	networkInterfaceTags map[string][]*ec2.Tag
+
+	availableAfter int
+	describeCounts map[string]int
+
+	errors map[string]*injectedError
+	calls  map[string]int
+	nextID int
+}
+
+func newFakeAWS() *fakeAWS {
+	return &fakeAWS{
+		vpcs:                      map[string]*ec2.Vpc{},
+		subnets:                   map[string]*ec2.Subnet{},
+		securityGroups:            map[string]*ec2.SecurityGroup{},
+		fileSystems:               map[string]*awsefs.FileSystemDescription{},
+		mountTargets:              map[string]*awsefs.MountTargetDescription{},
+		accessPoints:              map[string]*awsefs.AccessPointDescription{},
+		lifecycle:                 map[string][]*awsefs.LifecyclePolicy{},
+		mountTargetGroups:         map[string][]string{},
+		mountTargetIPAddressTypes: map[string]string{},
+		networkInterfaceTags:      map[string][]*ec2.Tag{},
+		policies:                  map[string]string{},
+		zoneIDs:                   map[string]string{},
+		describeCounts:            map[string]int{},
+		errors:                    map[string]*injectedError{},
+		calls:                     map[string]int{},
+	}
+}
+
+// withVPC adds a VPC with the given IPv4 and IPv6 CIDR blocks.
+func (f *fakeAWS) withVPC(vpcID string, ipv4CIDR string, ipv6CIDRs ...string) *fakeAWS {
+	vpc := &ec2.Vpc{
+		VpcId:     aws.String(vpcID),
+		CidrBlock: aws.String(ipv4CIDR),
+		CidrBlockAssociationSet: []*ec2.VpcCidrBlockAssociation{
+			{
+				CidrBlock:      aws.String(ipv4CIDR),
+				CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String(ec2.VpcCidrBlockStateCodeAssociated)},
+			},
+		},
+	}
+	for _, cidr := range ipv6CIDRs {
+		vpc.Ipv6CidrBlockAssociationSet = append(vpc.Ipv6CidrBlockAssociationSet, &ec2.VpcIpv6CidrBlockAssociation{
+			Ipv6CidrBlock:      aws.String(cidr),
+			Ipv6CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String(ec2.VpcCidrBlockStateCodeAssociated)},
+		})
+	}
+	f.vpcs[vpcID] = vpc
+	return f
+}
+
+// withSubnet adds a subnet with the given tag keys.
+func (f *fakeAWS) withSubnet(vpcID, subnetID, zone string, tagKeys ...string) *fakeAWS {
+	if _, found := f.zoneIDs[zone]; !found {
+		f.zoneIDs[zone] = "use1-az" + zone[len(zone)-1:]
+	}
+	subnet := &ec2.Subnet{
+		VpcId:              aws.String(vpcID),
+		SubnetId:           aws.String(subnetID),
+		AvailabilityZone:   aws.String(zone),
+		AvailabilityZoneId: aws.String(f.zoneIDs[zone]),
+	}
+	for _, key := range tagKeys {
+		subnet.Tags = append(subnet.Tags, &ec2.Tag{Key: aws.String(key), Value: aws.String("")})
+	}
+	f.subnets[subnetID] = subnet
+	return f
+}
+
+// withInstance adds an instance in the given subnet, the subnet must exist.
+func (f *fakeAWS) withInstance(instanceID, subnetID string, securityGroupIDs ...string) *fakeAWS {
+	subnet := f.subnets[subnetID]
+	instance := &ec2.Instance{
+		InstanceId: aws.String(instanceID),
+		SubnetId:   aws.String(subnetID),
+		VpcId:      subnet.VpcId,
+		Placement:  &ec2.Placement{AvailabilityZone: subnet.AvailabilityZone},
+	}
+	for _, id := range securityGroupIDs {
+		instance.SecurityGroups = append(instance.SecurityGroups, &ec2.GroupIdentifier{GroupId: aws.String(id)})
+	}
+	f.instances = append(f.instances, instance)
+	return f
+}
+
+// failOn makes the operation fail after the given number of successful calls.
+func (f *fakeAWS) failOn(operation string, after int, err error) *fakeAWS {
+	f.errors[operation] = &injectedError{after: after, err: err}
+	return f
+}
+
+func (f *fakeAWS) call(operation string) error {
+	count := f.calls[operation]
+	f.calls[operation] = count + 1
+	if injected, found := f.errors[operation]; found && count >= injected.after {
+		return injected.err
+	}
+	return nil
+}
+
+func (f *fakeAWS) newID(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s-%08d", prefix, f.nextID)
+}
+
+// tick moves a resource to the next lifecycle state. It returns false when
+// the resource disappeared.
+func (f *fakeAWS) tick(id string, state **string) bool {
+	f.describeCounts[id]++
+	switch aws.StringValue(*state) {
+	case awsefs.LifeCycleStateCreating:
+		if f.describeCounts[id] >= f.availableAfter {
+			*state = aws.String(awsefs.LifeCycleStateAvailable)
+		}
+	case awsefs.LifeCycleStateDeleting:
+		*state = aws.String(awsefs.LifeCycleStateDeleted)
+	case awsefs.LifeCycleStateDeleted:
+		return false
+	}
+	return true
+}
+
+func (f *fakeAWS) DescribeAvailabilityZones(input *ec2.DescribeAvailabilityZonesInput) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	if err := f.call("DescribeAvailabilityZones"); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeAvailabilityZonesOutput{}
+	for _, zone := range sortedKeys(f.zoneIDs) {
+		output.AvailabilityZones = append(output.AvailabilityZones, &ec2.AvailabilityZone{
+			ZoneName: aws.String(zone),
+			ZoneId:   aws.String(f.zoneIDs[zone]),
+		})
+	}
+	return output, nil
+}
+
+func (f *fakeAWS) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	if err := f.call("DescribeInstances"); err != nil {
+		return nil, err
+	}
+	ids := aws.StringValueSlice(input.InstanceIds)
+	var matching []*ec2.Instance
+	for _, instance := range f.instances {
+		for _, id := range ids {
+			if id == *instance.InstanceId {
+				matching = append(matching, instance)
+			}
+		}
+	}
+
+	start := 0
+	if input.NextToken != nil {
+		start, _ = strconv.Atoi(*input.NextToken)
+	}
+	end := len(matching)
+	if f.instancesPage > 0 && start+f.instancesPage < end {
+		end = start + f.instancesPage
+	}
+	output := &ec2.DescribeInstancesOutput{}
+	if start < end {
+		output.Reservations = []*ec2.Reservation{{Instances: matching[start:end]}}
+	}
+	if end < len(matching) {
+		output.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+func (f *fakeAWS) DescribeVpcs(input *ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error) {
+	if err := f.call("DescribeVpcs"); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeVpcsOutput{}
+	for _, id := range aws.StringValueSlice(input.VpcIds) {
+		if vpc, found := f.vpcs[id]; found {
+			output.Vpcs = append(output.Vpcs, vpc)
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeAWS) DescribeSubnets(input *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	if err := f.call("DescribeSubnets"); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeSubnetsOutput{}
+	if len(input.SubnetIds) > 0 {
+		for _, id := range aws.StringValueSlice(input.SubnetIds) {
+			subnet, found := f.subnets[id]
+			if !found {
+				return nil, awserr.New("InvalidSubnetID.NotFound", fmt.Sprintf("subnet %s not found", id), nil)
+			}
+			output.Subnets = append(output.Subnets, subnet)
+		}
+		return output, nil
+	}
+	for _, id := range sortedKeys(f.subnets) {
+		subnet := f.subnets[id]
+		if matchesFilters(input.Filters, map[string]string{"vpc-id": *subnet.VpcId}, subnet.Tags) {
+			output.Subnets = append(output.Subnets, subnet)
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeAWS) DescribeSecurityGroups(input *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	if err := f.call("DescribeSecurityGroups"); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeSecurityGroupsOutput{}
+	for _, id := range sortedKeys(f.securityGroups) {
+		sg := f.securityGroups[id]
+		fields := map[string]string{"vpc-id": *sg.VpcId, "group-name": *sg.GroupName}
+		if matchesFilters(input.Filters, fields, sg.Tags) {
+			output.SecurityGroups = append(output.SecurityGroups, sg)
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeAWS) CreateSecurityGroup(input *ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
+	if err := f.call("CreateSecurityGroup"); err != nil {
+		return nil, err
+	}
+	id := f.newID("sg")
+	sg := &ec2.SecurityGroup{
+		GroupId:   aws.String(id),
+		GroupName: input.GroupName,
+		VpcId:     input.VpcId,
+	}
+	for _, spec := range input.TagSpecifications {
+		sg.Tags = append(sg.Tags, spec.Tags...)
+	}
+	f.securityGroups[id] = sg
+	return &ec2.CreateSecurityGroupOutput{GroupId: aws.String(id)}, nil
+}
+
+func (f *fakeAWS) DeleteSecurityGroup(input *ec2.DeleteSecurityGroupInput) (*ec2.DeleteSecurityGroupOutput, error) {
+	if err := f.call("DeleteSecurityGroup"); err != nil {
+		return nil, err
+	}
+	id := aws.StringValue(input.GroupId)
+	if _, found := f.securityGroups[id]; !found {
+		return nil, awserr.New("InvalidGroup.NotFound", fmt.Sprintf("security group %s not found", id), nil)
+	}
+	for _, mt := range f.mountTargets {
+		if aws.StringValue(mt.LifeCycleState) != awsefs.LifeCycleStateDeleted && f.mountTargetUsesGroup(mt, id) {
+			return nil, awserr.New(dependencyViolationErrorCode, fmt.Sprintf("security group %s is in use", id), nil)
+		}
+	}
+	delete(f.securityGroups, id)
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+func (f *fakeAWS) AuthorizeSecurityGroupIngress(input *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	if err := f.call("AuthorizeSecurityGroupIngress"); err != nil {
+		return nil, err
+	}
+	sg, found := f.securityGroups[aws.StringValue(input.GroupId)]
+	if !found {
+		return nil, awserr.New("InvalidGroup.NotFound", "security group not found", nil)
+	}
+	sg.IpPermissions = append(sg.IpPermissions, input.IpPermissions...)
+	return &ec2.AuthorizeSecurityGroupIngressOutput{Return: aws.Bool(true)}, nil
+}
+
+func (f *fakeAWS) RevokeSecurityGroupIngress(input *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	if err := f.call("RevokeSecurityGroupIngress"); err != nil {
+		return nil, err
+	}
+	sg, found := f.securityGroups[aws.StringValue(input.GroupId)]
+	if !found {
+		return nil, awserr.New("InvalidGroup.NotFound", "security group not found", nil)
+	}
+	sg.IpPermissions = nil
+	return &ec2.RevokeSecurityGroupIngressOutput{Return: aws.Bool(true)}, nil
+}
+
+func (f *fakeAWS) CreateFileSystem(input *awsefs.CreateFileSystemInput) (*awsefs.FileSystemDescription, error) {
+	if err := f.call("CreateFileSystem"); err != nil {
+		return nil, err
+	}
+	id := f.newID("fs")
+	fs := &awsefs.FileSystemDescription{
+		FileSystemId:         aws.String(id),
+		FileSystemArn:        aws.String("arn:aws:elasticfilesystem:us-east-1:123456789012:file-system/" + id),
+		CreationToken:        input.CreationToken,
+		AvailabilityZoneName: input.AvailabilityZoneName,
+		PerformanceMode:      input.PerformanceMode,
+		ThroughputMode:       input.ThroughputMode,
+		KmsKeyId:             input.KmsKeyId,
+		Encrypted:            input.Encrypted,
+		LifeCycleState:       aws.String(awsefs.LifeCycleStateCreating),
+		Tags:                 input.Tags,
+	}
+	for _, tag := range input.Tags {
+		if aws.StringValue(tag.Key) == "Name" {
+			fs.Name = tag.Value
+		}
+	}
+	f.fileSystems[id] = fs
+	return fs, nil
+}
+
+func (f *fakeAWS) DescribeFileSystems(input *awsefs.DescribeFileSystemsInput) (*awsefs.DescribeFileSystemsOutput, error) {
+	if err := f.call("DescribeFileSystems"); err != nil {
+		return nil, err
+	}
+	if input.FileSystemId != nil {
+		if _, found := f.fileSystems[*input.FileSystemId]; !found {
+			return nil, awserr.New(awsefs.ErrCodeFileSystemNotFound, "filesystem not found", nil)
+		}
+	}
+	output := &awsefs.DescribeFileSystemsOutput{}
+	for _, id := range sortedKeys(f.fileSystems) {
+		fs := f.fileSystems[id]
+		if input.FileSystemId != nil && *input.FileSystemId != id {
+			continue
+		}
+		if input.CreationToken != nil && aws.StringValue(fs.CreationToken) != *input.CreationToken {
+			continue
+		}
+		if !f.tick(id, &fs.LifeCycleState) {
+			delete(f.fileSystems, id)
+			continue
+		}
+		output.FileSystems = append(output.FileSystems, fs)
+	}
+	return output, nil
+}
+
+func (f *fakeAWS) DeleteFileSystem(input *awsefs.DeleteFileSystemInput) (*awsefs.DeleteFileSystemOutput, error) {
+	if err := f.call("DeleteFileSystem"); err != nil {
+		return nil, err
+	}
+	id := aws.StringValue(input.FileSystemId)
+	fs, found := f.fileSystems[id]
+	if !found {
+		return nil, awserr.New(awsefs.ErrCodeFileSystemNotFound, "filesystem not found", nil)
+	}
+	for _, mt := range f.mountTargets {
+		if *mt.FileSystemId == id && aws.StringValue(mt.LifeCycleState) != awsefs.LifeCycleStateDeleted {
+			return nil, awserr.New(awsefs.ErrCodeFileSystemInUse, "filesystem has mount targets", nil)
+		}
+	}
+	fs.LifeCycleState = aws.String(awsefs.LifeCycleStateDeleting)
+	return &awsefs.DeleteFileSystemOutput{}, nil
+}
+
+func (f *fakeAWS) PutLifecycleConfiguration(input *awsefs.PutLifecycleConfigurationInput) (*awsefs.PutLifecycleConfigurationOutput, error) {
+	if err := f.call("PutLifecycleConfiguration"); err != nil {
+		return nil, err
+	}
+	f.lifecycle[aws.StringValue(input.FileSystemId)] = input.LifecyclePolicies
+	return &awsefs.PutLifecycleConfigurationOutput{LifecyclePolicies: input.LifecyclePolicies}, nil
+}
+
+func (f *fakeAWS) PutFileSystemPolicy(input *awsefs.PutFileSystemPolicyInput) (*awsefs.PutFileSystemPolicyOutput, error) {
+	if err := f.call("PutFileSystemPolicy"); err != nil {
+		return nil, err
+	}
+	if _, found := f.fileSystems[aws.StringValue(input.FileSystemId)]; !found {
+		return nil, awserr.New(awsefs.ErrCodeFileSystemNotFound, "filesystem not found", nil)
+	}
+	f.policies[aws.StringValue(input.FileSystemId)] = aws.StringValue(input.Policy)
+	return &awsefs.PutFileSystemPolicyOutput{FileSystemId: input.FileSystemId, Policy: input.Policy}, nil
+}
+
+func (f *fakeAWS) CreateTags(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	if err := f.call("CreateTags"); err != nil {
+		return nil, err
+	}
+	for _, id := range aws.StringValueSlice(input.Resources) {
+		f.networkInterfaceTags[id] = append(f.networkInterfaceTags[id], input.Tags...)
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (f *fakeAWS) CreateMountTarget(_ context.Context, input *efsv2.CreateMountTargetInput, _ ...func(*efsv2.Options)) (*efsv2.CreateMountTargetOutput, error) {
+	if err := f.call("CreateMountTarget"); err != nil {
+		return nil, err
+	}
+	subnet, found := f.subnets[aws.StringValue(input.SubnetId)]
+	if !found {
+		return nil, &efstypes.SubnetNotFound{Message: aws.String("subnet not found")}
+	}
+	for _, mt := range f.mountTargets {
+		if *mt.FileSystemId == *input.FileSystemId && *mt.AvailabilityZoneName == *subnet.AvailabilityZone {
+			return nil, &efstypes.MountTargetConflict{Message: aws.String("mount target already exists in the zone")}
+		}
+	}
+	id := f.newID("fsmt")
+	mt := &awsefs.MountTargetDescription{
+		MountTargetId:        aws.String(id),
+		FileSystemId:         input.FileSystemId,
+		SubnetId:             input.SubnetId,
+		AvailabilityZoneName: subnet.AvailabilityZone,
+		IpAddress:            aws.String(fmt.Sprintf("10.0.0.%d", f.nextID)),
+		NetworkInterfaceId:   aws.String(fmt.Sprintf("eni-%08d", f.nextID)),
+		LifeCycleState:       aws.String(awsefs.LifeCycleStateCreating),
+	}
+	f.mountTargets[id] = mt
+	f.mountTargetGroups[id] = input.SecurityGroups
+	f.mountTargetIPAddressTypes[id] = string(input.IpAddressType)
+	return &efsv2.CreateMountTargetOutput{
+		MountTargetId:        mt.MountTargetId,
+		FileSystemId:         mt.FileSystemId,
+		SubnetId:             mt.SubnetId,
+		AvailabilityZoneName: mt.AvailabilityZoneName,
+		IpAddress:            mt.IpAddress,
+		NetworkInterfaceId:   mt.NetworkInterfaceId,
+		LifeCycleState:       efstypes.LifeCycleState(*mt.LifeCycleState),
+	}, nil
+}
+
+func (f *fakeAWS) DescribeMountTargets(input *awsefs.DescribeMountTargetsInput) (*awsefs.DescribeMountTargetsOutput, error) {
+	if err := f.call("DescribeMountTargets"); err != nil {
+		return nil, err
+	}
+	output := &awsefs.DescribeMountTargetsOutput{}
+	for _, id := range sortedKeys(f.mountTargets) {
+		mt := f.mountTargets[id]
+		if *mt.FileSystemId != aws.StringValue(input.FileSystemId) {
+			continue
+		}
+		if !f.tick(id, &mt.LifeCycleState) {
+			delete(f.mountTargets, id)
+			continue
+		}
+		output.MountTargets = append(output.MountTargets, mt)
+	}
+	return output, nil
+}
+
+func (f *fakeAWS) DeleteMountTarget(input *awsefs.DeleteMountTargetInput) (*awsefs.DeleteMountTargetOutput, error) {
+	if err := f.call("DeleteMountTarget"); err != nil {
+		return nil, err
+	}
+	mt, found := f.mountTargets[aws.StringValue(input.MountTargetId)]
+	if !found {
+		return nil, awserr.New(awsefs.ErrCodeMountTargetNotFound, "mount target not found", nil)
+	}
+	mt.LifeCycleState = aws.String(awsefs.LifeCycleStateDeleting)
+	return &awsefs.DeleteMountTargetOutput{}, nil
+}
+
+func (f *fakeAWS) CreateAccessPoint(input *awsefs.CreateAccessPointInput) (*awsefs.CreateAccessPointOutput, error) {
+	if err := f.call("CreateAccessPoint"); err != nil {
+		return nil, err
+	}
+	id := f.newID("fsap")
+	f.accessPoints[id] = &awsefs.AccessPointDescription{
+		AccessPointId:  aws.String(id),
+		FileSystemId:   input.FileSystemId,
+		PosixUser:      input.PosixUser,
+		RootDirectory:  input.RootDirectory,
+		Tags:           input.Tags,
+		LifeCycleState: aws.String(awsefs.LifeCycleStateCreating),
+	}
+	return &awsefs.CreateAccessPointOutput{AccessPointId: aws.String(id)}, nil
+}
+
+func (f *fakeAWS) DescribeAccessPoints(input *awsefs.DescribeAccessPointsInput) (*awsefs.DescribeAccessPointsOutput, error) {
+	if err := f.call("DescribeAccessPoints"); err != nil {
+		return nil, err
+	}
+	output := &awsefs.DescribeAccessPointsOutput{}
+	for _, id := range sortedKeys(f.accessPoints) {
+		ap := f.accessPoints[id]
+		if *ap.FileSystemId != aws.StringValue(input.FileSystemId) {
+			continue
+		}
+		if !f.tick(id, &ap.LifeCycleState) {
+			delete(f.accessPoints, id)
+			continue
+		}
+		output.AccessPoints = append(output.AccessPoints, ap)
+	}
+	return output, nil
+}
+
+func (f *fakeAWS) DeleteAccessPoint(input *awsefs.DeleteAccessPointInput) (*awsefs.DeleteAccessPointOutput, error) {
+	if err := f.call("DeleteAccessPoint"); err != nil {
+		return nil, err
+	}
+	ap, found := f.accessPoints[aws.StringValue(input.AccessPointId)]
+	if !found {
+		return nil, awserr.New(awsefs.ErrCodeAccessPointNotFound, "access point not found", nil)
+	}
+	ap.LifeCycleState = aws.String(awsefs.LifeCycleStateDeleting)
+	return &awsefs.DeleteAccessPointOutput{}, nil
+}
+
+func (f *fakeAWS) mountTargetUsesGroup(mt *awsefs.MountTargetDescription, groupID string) bool {
+	for _, id := range f.mountTargetGroups[*mt.MountTargetId] {
+		if id == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFilters evaluates EC2 filters on a resource. Supported filters are
+// the given fields, "tag:<key>" and "tag-key".
+func matchesFilters(filters []*ec2.Filter, fields map[string]string, tags []*ec2.Tag) bool {
+	tagValues := map[string]string{}
+	for _, tag := range tags {
+		tagValues[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+		values := aws.StringValueSlice(filter.Values)
+		var value string
+		var found bool
+		switch {
+		case name == "tag-key":
+			for _, key := range values {
+				if _, found = tagValues[key]; found {
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+			continue
+		case strings.HasPrefix(name, "tag:"):
+			value, found = tagValues[strings.TrimPrefix(name, "tag:")]
+		default:
+			value, found = fields[name]
+		}
+		if !found || !contains(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+package efscreate
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	nfsPort = 2049
+
+	// IngressSourceVPCCIDR allows NFS traffic from all CIDR blocks of the cluster VPC.
+	IngressSourceVPCCIDR = "vpc-cidr"
+	// IngressSourceNodeSecurityGroups allows NFS traffic only from security groups
+	// of the cluster nodes.
+	IngressSourceNodeSecurityGroups = "node-security-groups"
+)
+
+// getVPCCIDRBlocks returns all IPv4 and IPv6 CIDR blocks associated with the VPC.
+func getVPCCIDRBlocks(vpc *ec2.Vpc) ([]string, []string) {
+	ipv4Set := sets.NewString()
+	for _, association := range vpc.CidrBlockAssociationSet {
+		if association.CidrBlockState != nil &&
+			aws.StringValue(association.CidrBlockState.State) != ec2.VpcCidrBlockStateCodeAssociated {
+			continue
+		}
+		ipv4Set.Insert(aws.StringValue(association.CidrBlock))
+	}
+	if vpc.CidrBlock != nil {
+		ipv4Set.Insert(*vpc.CidrBlock)
+	}
+
+	ipv6Set := sets.NewString()
+	for _, association := range vpc.Ipv6CidrBlockAssociationSet {
+		if association.Ipv6CidrBlockState != nil &&
+			aws.StringValue(association.Ipv6CidrBlockState.State) != ec2.VpcCidrBlockStateCodeAssociated {
+			continue
+		}
+		ipv6Set.Insert(aws.StringValue(association.Ipv6CidrBlock))
+	}
+	return ipv4Set.List(), ipv6Set.List()
+}
+
+// getMissingFireWallRule returns NFS ingress rule with all sources that the
+// security group does not allow yet, or nil if all of them are allowed.
+func (efs *EFS) getMissingFireWallRule(sg *ec2.SecurityGroup) *ec2.IpPermission {
+	existingIPv4 := sets.NewString()
+	existingIPv6 := sets.NewString()
+	existingGroups := sets.NewString()
+	for _, perm := range sg.IpPermissions {
+		if aws.StringValue(perm.IpProtocol) != "tcp" ||
+			aws.Int64Value(perm.FromPort) != nfsPort ||
+			aws.Int64Value(perm.ToPort) != nfsPort {
+			continue
+		}
+		for _, ipRange := range perm.IpRanges {
+			existingIPv4.Insert(aws.StringValue(ipRange.CidrIp))
+		}
+		for _, ipRange := range perm.Ipv6Ranges {
+			existingIPv6.Insert(aws.StringValue(ipRange.CidrIpv6))
+		}
+		for _, pair := range perm.UserIdGroupPairs {
+			existingGroups.Insert(aws.StringValue(pair.GroupId))
+		}
+	}
+
+	rule := &ec2.IpPermission{
+		IpProtocol: aws.String("tcp"),
+		FromPort:   aws.Int64(nfsPort),
+		ToPort:     aws.Int64(nfsPort),
+	}
+	if efs.options.IngressSource == IngressSourceNodeSecurityGroups {
+		for _, groupID := range efs.nodeSecurityGroupIDs {
+			if !existingGroups.Has(groupID) {
+				rule.UserIdGroupPairs = append(rule.UserIdGroupPairs, &ec2.UserIdGroupPair{GroupId: aws.String(groupID)})
+			}
+		}
+	} else {
+		for _, cidr := range efs.ipv4CIDRBlocks {
+			if !existingIPv4.Has(cidr) {
+				rule.IpRanges = append(rule.IpRanges, &ec2.IpRange{CidrIp: aws.String(cidr)})
+			}
+		}
+		for _, cidr := range efs.ipv6CIDRBlocks {
+			if !existingIPv6.Has(cidr) {
+				rule.Ipv6Ranges = append(rule.Ipv6Ranges, &ec2.Ipv6Range{CidrIpv6: aws.String(cidr)})
+			}
+		}
+	}
+
+	if len(rule.IpRanges) == 0 && len(rule.Ipv6Ranges) == 0 && len(rule.UserIdGroupPairs) == 0 {
+		return nil
+	}
+	return rule
+}
+
+func (efs *EFS) addFireWallRule(rule *ec2.IpPermission) (bool, error) {
+	ruleInput := ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(efs.resources.securityGroupID),
+		IpPermissions: []*ec2.IpPermission{rule},
+	}
+	response, err := efs.fsAccountClient.AuthorizeSecurityGroupIngress(&ruleInput)
+	if err != nil {
+		return false, fmt.Errorf("error creating firewall rule: %v", err)
+	}
+	efs.registerUndo(fmt.Sprintf("firewall rule in security group %s", *ruleInput.GroupId), func() error {
+		return efs.revokeFireWallRule(*ruleInput.GroupId, rule)
+	})
+	return *response.Return, nil
+}
+
+func (efs *EFS) revokeFireWallRule(sgID string, rule *ec2.IpPermission) error {
+	_, err := efs.fsAccountClient.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+		GroupId:       aws.String(sgID),
+		IpPermissions: []*ec2.IpPermission{rule},
+	})
+	if err != nil {
+		return fmt.Errorf("error revoking firewall rule: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package efscreate
+
+import (
+	"fmt"
+
+	efstypes "github.com/aws/aws-sdk-go-v2/service/efs/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// IP address types of EFS mount targets.
+const (
+	IPAddressTypeAuto      = "auto"
+	IPAddressTypeIPv4Only  = string(efstypes.IpAddressTypeIpv4Only)
+	IPAddressTypeDualStack = string(efstypes.IpAddressTypeDualStack)
+	IPAddressTypeIPv6Only  = string(efstypes.IpAddressTypeIpv6Only)
+)
+
+// getSubnetIPAddressTypes returns IP address type of a mount target for each
+// of the given subnets, based on CIDR blocks associated with the subnets.
+func (efs *EFS) getSubnetIPAddressTypes(subnetIDs []string) (map[string]string, error) {
+	ipAddressTypes := map[string]string{}
+	if len(subnetIDs) == 0 {
+		return ipAddressTypes, nil
+	}
+	response, err := efs.fsAccountClient.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		SubnetIds: aws.StringSlice(subnetIDs),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing subnets: %v", err)
+	}
+	for _, subnet := range response.Subnets {
+		hasIPv6 := false
+		for _, association := range subnet.Ipv6CidrBlockAssociationSet {
+			if association.Ipv6CidrBlockState == nil ||
+				aws.StringValue(association.Ipv6CidrBlockState.State) == ec2.SubnetCidrBlockStateCodeAssociated {
+				hasIPv6 = true
+			}
+		}
+		switch {
+		case aws.BoolValue(subnet.Ipv6Native):
+			ipAddressTypes[*subnet.SubnetId] = IPAddressTypeIPv6Only
+		case hasIPv6:
+			ipAddressTypes[*subnet.SubnetId] = IPAddressTypeDualStack
+		default:
+			ipAddressTypes[*subnet.SubnetId] = IPAddressTypeIPv4Only
+		}
+	}
+	return ipAddressTypes, nil
+}
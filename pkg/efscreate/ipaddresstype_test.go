@@ -0,0 +1,39 @@
+package efscreate
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestCreateEFSVolumeIPAddressType(t *testing.T) {
+	fake := newTestCluster()
+	// subnet-a is dual-stack, subnet-b is IPv6 only, subnet-c is IPv4 only
+	fake.subnets["subnet-a"].Ipv6CidrBlockAssociationSet = []*ec2.SubnetIpv6CidrBlockAssociation{{
+		Ipv6CidrBlock:      aws.String("2600:1f18::/64"),
+		Ipv6CidrBlockState: &ec2.SubnetCidrBlockState{State: aws.String(ec2.SubnetCidrBlockStateCodeAssociated)},
+	}}
+	fake.subnets["subnet-b"].Ipv6Native = aws.Bool(true)
+
+	options := newTestOptions()
+	options.IPAddressType = IPAddressTypeAuto
+	if _, err := newTestEFS(fake, options).CreateEFSVolume(newTestNodes("i-1", "i-2", "i-3")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"subnet-a": IPAddressTypeDualStack,
+		"subnet-b": IPAddressTypeIPv6Only,
+		"subnet-c": IPAddressTypeIPv4Only,
+	}
+	for id, mt := range fake.mountTargets {
+		subnet := aws.StringValue(mt.SubnetId)
+		if got := fake.mountTargetIPAddressTypes[id]; got != expected[subnet] {
+			t.Errorf("expected IP address type %s of mount target in %s, got %s", expected[subnet], subnet, got)
+		}
+	}
+	if len(fake.mountTargets) != len(expected) {
+		t.Errorf("expected %d mount targets, got %d", len(expected), len(fake.mountTargets))
+	}
+}
@@ -0,0 +1,198 @@
+package efscreate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+	v1 "github.com/openshift/api/config/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Options configures how create-efs-volume creates and deletes AWS resources.
+type Options struct {
+	// UseLocalAWSCredentials uses credentials of the local machine instead of
+	// credentials loaded from the cluster.
+	UseLocalAWSCredentials bool
+	// Credentials selects other sources of AWS credentials.
+	Credentials CredentialsOptions
+	// KeepOnFailure keeps resources created by a failed run instead of
+	// rolling them back, so they can be inspected.
+	KeepOnFailure bool
+	// ReportFile is the path where a JSON or YAML report of the created
+	// resources is written. The format is chosen by the file extension.
+	ReportFile string
+	// FileSystem configures the created EFS file system.
+	FileSystem FileSystemOptions
+	// IngressSource selects what is allowed to reach the mount targets, either
+	// IngressSourceVPCCIDR or IngressSourceNodeSecurityGroups.
+	IngressSource string
+	// IPAddressType of mount targets. IPAddressTypeAuto detects it from CIDR
+	// blocks associated with each subnet.
+	IPAddressType string
+	// SubnetIDs are subnets to create mount targets in, at most one per
+	// availability zone. Empty discovers them from the cluster.
+	SubnetIDs []string
+	// AccessPoints to create in the file system.
+	AccessPoints []AccessPointOptions
+	// StaticPVFile is the path where static PersistentVolume and
+	// PersistentVolumeClaim manifests are written, one pair per access point
+	// or a single pair for the whole file system when there are no access points.
+	StaticPVFile string
+	// OneZone is an availability zone where a One Zone file system is created.
+	// Empty creates a Regional file system.
+	OneZone string
+	// CrossAccount creates the file system in another AWS account.
+	CrossAccount CrossAccountOptions
+	// DryRun only discovers the cluster and prints what would be created,
+	// without changing any AWS resources or writing any files.
+	DryRun bool
+}
+
+// DestroyOptions configures how create-efs-volume destroy finds and deletes
+// AWS resources. Resources are found by their cluster tag, so only options
+// that select the AWS accounts are needed.
+type DestroyOptions struct {
+	// UseLocalAWSCredentials uses credentials of the local machine instead of
+	// credentials loaded from the cluster.
+	UseLocalAWSCredentials bool
+	// Credentials selects other sources of AWS credentials.
+	Credentials CredentialsOptions
+	// CrossAccount deletes the file system in another AWS account. Only its
+	// role is used.
+	CrossAccount CrossAccountOptions
+}
+
+// FileSystemOptions configures parameters of a newly created EFS file system.
+// They are not applied to a file system adopted from a previous run, except
+// for the lifecycle policies.
+type FileSystemOptions struct {
+	// PerformanceMode is generalPurpose or maxIO.
+	PerformanceMode string
+	// ThroughputMode is bursting, provisioned or elastic. Empty uses the AWS default.
+	ThroughputMode string
+	// ProvisionedThroughputInMibps is required for the provisioned throughput mode.
+	ProvisionedThroughputInMibps float64
+	// KMSKeyID is ID or ARN of a customer managed KMS key used for encryption.
+	// Empty uses the AWS managed key.
+	KMSKeyID string
+	// TransitionToIA is a lifecycle rule such as AFTER_30_DAYS.
+	TransitionToIA string
+	// TransitionToArchive is a lifecycle rule such as AFTER_90_DAYS.
+	TransitionToArchive string
+	// Backup enables automatic backups.
+	Backup bool
+}
+
+// Validate checks the options before any AWS call is made.
+func (o *Options) Validate() error {
+	if len(o.ReportFile) > 0 {
+		if err := validateReportFile(o.ReportFile); err != nil {
+			return err
+		}
+	}
+	if o.IngressSource != IngressSourceVPCCIDR && o.IngressSource != IngressSourceNodeSecurityGroups {
+		return fmt.Errorf("invalid ingress source %q, expected %s or %s", o.IngressSource, IngressSourceVPCCIDR, IngressSourceNodeSecurityGroups)
+	}
+	switch o.IPAddressType {
+	case IPAddressTypeAuto, IPAddressTypeIPv4Only, IPAddressTypeDualStack, IPAddressTypeIPv6Only:
+	default:
+		return fmt.Errorf("invalid IP address type %q, expected one of %v", o.IPAddressType,
+			[]string{IPAddressTypeAuto, IPAddressTypeIPv4Only, IPAddressTypeDualStack, IPAddressTypeIPv6Only})
+	}
+	if o.OneZone != "" && o.FileSystem.PerformanceMode != awsefs.PerformanceModeGeneralPurpose {
+		return fmt.Errorf("One Zone filesystem requires %s performance mode", awsefs.PerformanceModeGeneralPurpose)
+	}
+	if err := o.Credentials.Validate(o.UseLocalAWSCredentials); err != nil {
+		return err
+	}
+	if err := o.CrossAccount.Validate(); err != nil {
+		return err
+	}
+	if o.CrossAccount.Enabled() {
+		// Security groups and zone names of the cluster account can't be used in the filesystem account
+		if o.IngressSource != IngressSourceVPCCIDR {
+			return fmt.Errorf("cross-account mode supports only %s ingress source", IngressSourceVPCCIDR)
+		}
+		if o.OneZone != "" {
+			return fmt.Errorf("One Zone filesystem is not supported in cross-account mode")
+		}
+	}
+	return o.FileSystem.Validate()
+}
+
+// Validate checks the destroy options before any AWS call is made.
+func (o *DestroyOptions) Validate() error {
+	if err := o.Credentials.Validate(o.UseLocalAWSCredentials); err != nil {
+		return err
+	}
+	return o.CrossAccount.validateRole()
+}
+
+// createOptions returns Options of the resources to delete, as they were
+// created.
+func (o *DestroyOptions) createOptions() Options {
+	return Options{
+		UseLocalAWSCredentials: o.UseLocalAWSCredentials,
+		Credentials:            o.Credentials,
+		CrossAccount:           o.CrossAccount,
+	}
+}
+
+// validateInfrastructure checks that the cluster can be identified. Names and
+// tags of all resources contain the infrastructure name.
+func validateInfrastructure(infra *v1.Infrastructure) error {
+	if infra.Status.InfrastructureName == "" {
+		return fmt.Errorf("infrastructure %s has no infrastructure name", infra.Name)
+	}
+	if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.AWS == nil || infra.Status.PlatformStatus.AWS.Region == "" {
+		return fmt.Errorf("infrastructure %s has no AWS region, the cluster is not on AWS", infra.Name)
+	}
+	return nil
+}
+
+// Validate checks the file system options before any AWS call is made.
+func (o *FileSystemOptions) Validate() error {
+	if !sets.NewString(awsefs.PerformanceMode_Values()...).Has(o.PerformanceMode) {
+		return fmt.Errorf("invalid performance mode %q, expected one of %v", o.PerformanceMode, awsefs.PerformanceMode_Values())
+	}
+
+	if o.ThroughputMode != "" && !sets.NewString(awsefs.ThroughputMode_Values()...).Has(o.ThroughputMode) {
+		return fmt.Errorf("invalid throughput mode %q, expected one of %v", o.ThroughputMode, awsefs.ThroughputMode_Values())
+	}
+	if o.ThroughputMode == awsefs.ThroughputModeProvisioned && o.ProvisionedThroughputInMibps < 1 {
+		return fmt.Errorf("provisioned throughput mode requires provisioned throughput of at least 1 MiB/s")
+	}
+	if o.ThroughputMode != awsefs.ThroughputModeProvisioned && o.ProvisionedThroughputInMibps != 0 {
+		return fmt.Errorf("provisioned throughput can be set only with %s throughput mode", awsefs.ThroughputModeProvisioned)
+	}
+	if o.ThroughputMode == awsefs.ThroughputModeElastic && o.PerformanceMode != awsefs.PerformanceModeGeneralPurpose {
+		return fmt.Errorf("%s throughput mode requires %s performance mode", awsefs.ThroughputModeElastic, awsefs.PerformanceModeGeneralPurpose)
+	}
+
+	if o.TransitionToIA != "" && !sets.NewString(awsefs.TransitionToIARules_Values()...).Has(o.TransitionToIA) {
+		return fmt.Errorf("invalid transition to IA %q, expected one of %v", o.TransitionToIA, awsefs.TransitionToIARules_Values())
+	}
+	if o.TransitionToArchive != "" {
+		if !sets.NewString(awsefs.TransitionToArchiveRules_Values()...).Has(o.TransitionToArchive) {
+			return fmt.Errorf("invalid transition to archive %q, expected one of %v", o.TransitionToArchive, awsefs.TransitionToArchiveRules_Values())
+		}
+		if o.ThroughputMode != awsefs.ThroughputModeElastic || o.PerformanceMode != awsefs.PerformanceModeGeneralPurpose {
+			return fmt.Errorf("transition to archive requires %s throughput mode and %s performance mode", awsefs.ThroughputModeElastic, awsefs.PerformanceModeGeneralPurpose)
+		}
+		if o.TransitionToIA != "" && transitionDays(o.TransitionToArchive) <= transitionDays(o.TransitionToIA) {
+			return fmt.Errorf("transition to archive (%s) must be later than transition to IA (%s)", o.TransitionToArchive, o.TransitionToIA)
+		}
+	}
+	return nil
+}
+
+// transitionDays returns number of days of a lifecycle rule such as AFTER_30_DAYS.
+func transitionDays(rule string) int {
+	days, err := strconv.Atoi(strings.Split(rule, "_")[1])
+	if err != nil {
+		return 0
+	}
+	return days
+}
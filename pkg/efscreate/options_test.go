@@ -0,0 +1,109 @@
+package efscreate
+
+import (
+	"testing"
+
+	v1 "github.com/openshift/api/config/v1"
+)
+
+func TestDestroyOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     DestroyOptions
+		expectedErr string
+	}{
+		{
+			name: "cluster credentials",
+		},
+		{
+			name: "cross-account role without create options",
+			options: DestroyOptions{
+				CrossAccount: CrossAccountOptions{RoleARN: "arn:aws:iam::222222222222:role/efs-admin", ExternalID: "test"},
+			},
+		},
+		{
+			name: "invalid cross-account role",
+			options: DestroyOptions{
+				CrossAccount: CrossAccountOptions{RoleARN: "efs-admin"},
+			},
+			expectedErr: `invalid role ARN "efs-admin"`,
+		},
+		{
+			name: "cross-account external ID without role",
+			options: DestroyOptions{
+				CrossAccount: CrossAccountOptions{ExternalID: "test"},
+			},
+			expectedErr: "require a role ARN",
+		},
+		{
+			name: "assumed role",
+			options: DestroyOptions{
+				Credentials: CredentialsOptions{AssumeRoleARN: "arn:aws:iam::111111111111:role/admin", ExternalID: "test"},
+			},
+		},
+		{
+			name: "external ID without assumed role",
+			options: DestroyOptions{
+				Credentials: CredentialsOptions{ExternalID: "test"},
+			},
+			expectedErr: "external ID requires a role ARN",
+		},
+		{
+			name: "secret with local credentials",
+			options: DestroyOptions{
+				UseLocalAWSCredentials: true,
+				Credentials:            CredentialsOptions{SecretName: "aws-creds"},
+			},
+			expectedErr: "can't be used with local AWS credentials",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkError(t, test.options.Validate(), test.expectedErr)
+		})
+	}
+}
+
+func TestValidateInfrastructure(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      v1.InfrastructureStatus
+		expectedErr string
+	}{
+		{
+			name: "AWS cluster",
+			status: v1.InfrastructureStatus{
+				InfrastructureName: testInfraName,
+				PlatformStatus: &v1.PlatformStatus{
+					Type: v1.AWSPlatformType,
+					AWS:  &v1.AWSPlatformStatus{Region: "us-east-1"},
+				},
+			},
+		},
+		{
+			name: "no infrastructure name",
+			status: v1.InfrastructureStatus{
+				PlatformStatus: &v1.PlatformStatus{
+					Type: v1.AWSPlatformType,
+					AWS:  &v1.AWSPlatformStatus{Region: "us-east-1"},
+				},
+			},
+			expectedErr: "has no infrastructure name",
+		},
+		{
+			name: "not on AWS",
+			status: v1.InfrastructureStatus{
+				InfrastructureName: testInfraName,
+				PlatformStatus:     &v1.PlatformStatus{Type: v1.GCPPlatformType},
+			},
+			expectedErr: "the cluster is not on AWS",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra := &v1.Infrastructure{Status: test.status}
+			infra.Name = infraGlobalName
+			checkError(t, validateInfrastructure(infra), test.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package efscreate
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// Actions of resources in a Plan.
+const (
+	PlanActionCreate = "create"
+	PlanActionReuse  = "reuse"
+)
+
+// Plan describes what CreateEFSVolume would do, as discovered by PlanEFSVolume.
+type Plan struct {
+	Region        string            `json:"region"`
+	VPCID         string            `json:"vpcID"`
+	CIDRBlock     string            `json:"cidrBlock"`
+	SecurityGroup SecurityGroupPlan `json:"securityGroup"`
+	FirewallRule  *FirewallRulePlan `json:"firewallRule,omitempty"`
+	FileSystem    FileSystemPlan    `json:"fileSystem"`
+	MountTargets  []MountTargetPlan `json:"mountTargets"`
+	AccessPoints  []AccessPointPlan `json:"accessPoints,omitempty"`
+}
+
+// SecurityGroupPlan describes the security group of the mount targets.
+type SecurityGroupPlan struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	ID     string `json:"id,omitempty"`
+}
+
+// FirewallRulePlan describes NFS ingress sources that are not allowed by the
+// security group yet.
+type FirewallRulePlan struct {
+	Port             int64    `json:"port"`
+	IPv4CIDRBlocks   []string `json:"ipv4CIDRBlocks,omitempty"`
+	IPv6CIDRBlocks   []string `json:"ipv6CIDRBlocks,omitempty"`
+	SecurityGroupIDs []string `json:"securityGroupIDs,omitempty"`
+}
+
+// FileSystemPlan describes the file system. Parameters other than the
+// lifecycle policies are not applied to a reused file system.
+type FileSystemPlan struct {
+	Action                       string  `json:"action"`
+	Name                         string  `json:"name"`
+	ID                           string  `json:"id,omitempty"`
+	AvailabilityZone             string  `json:"availabilityZone,omitempty"`
+	PerformanceMode              string  `json:"performanceMode"`
+	ThroughputMode               string  `json:"throughputMode,omitempty"`
+	ProvisionedThroughputInMibps float64 `json:"provisionedThroughputInMibps,omitempty"`
+	KMSKeyID                     string  `json:"kmsKeyID,omitempty"`
+	TransitionToIA               string  `json:"transitionToIA,omitempty"`
+	TransitionToArchive          string  `json:"transitionToArchive,omitempty"`
+	Backup                       bool    `json:"backup"`
+	// PolicyPrincipal is allowed to mount the file system in the cross-account mode
+	PolicyPrincipal string `json:"policyPrincipal,omitempty"`
+}
+
+// MountTargetPlan describes a mount target in a single subnet.
+type MountTargetPlan struct {
+	Action           string `json:"action"`
+	SubnetID         string `json:"subnetID"`
+	AvailabilityZone string `json:"availabilityZone"`
+	IPAddressType    string `json:"ipAddressType"`
+	ID               string `json:"id,omitempty"`
+}
+
+// AccessPointPlan describes an access point requested in Options.AccessPoints.
+type AccessPointPlan struct {
+	Action      string `json:"action"`
+	Path        string `json:"path"`
+	UID         int64  `json:"uid"`
+	GID         int64  `json:"gid"`
+	Permissions string `json:"permissions"`
+	ID          string `json:"id,omitempty"`
+}
+
+// PlanEFSVolume performs the same discovery as CreateEFSVolume, using only
+// read-only AWS calls, and returns the resources it would create or reuse.
+func (efs *EFS) PlanEFSVolume(nodes *corev1.NodeList) (*Plan, error) {
+	klog.V(4).Info("Loading AWS VPC")
+	err := efs.getSecurityInfo(efs.getInstanceIDs(nodes))
+	if err != nil {
+		return nil, err
+	}
+	fsOptions := efs.options.FileSystem
+	plan := &Plan{
+		Region:    efs.infra.Status.PlatformStatus.AWS.Region,
+		VPCID:     efs.vpcID,
+		CIDRBlock: efs.cidrBlock,
+		SecurityGroup: SecurityGroupPlan{
+			Action: PlanActionCreate,
+			Name:   efs.getSecurityGroupName(),
+		},
+		FileSystem: FileSystemPlan{
+			Action:                       PlanActionCreate,
+			Name:                         efs.getVolumeName(),
+			AvailabilityZone:             efs.options.OneZone,
+			PerformanceMode:              fsOptions.PerformanceMode,
+			ThroughputMode:               fsOptions.ThroughputMode,
+			ProvisionedThroughputInMibps: fsOptions.ProvisionedThroughputInMibps,
+			KMSKeyID:                     fsOptions.KMSKeyID,
+			TransitionToIA:               fsOptions.TransitionToIA,
+			TransitionToArchive:          fsOptions.TransitionToArchive,
+			Backup:                       fsOptions.Backup,
+			PolicyPrincipal:              efs.options.CrossAccount.DriverRoleARN,
+		},
+		MountTargets: []MountTargetPlan{},
+	}
+
+	klog.V(4).Info("Looking for SecurityGroup")
+	sg, err := efs.findSecurityGroup()
+	if err != nil {
+		return nil, err
+	}
+	if sg != nil {
+		plan.SecurityGroup.Action = PlanActionReuse
+		plan.SecurityGroup.ID = *sg.GroupId
+	} else {
+		sg = &ec2.SecurityGroup{}
+	}
+	if rule := efs.getMissingFireWallRule(sg); rule != nil {
+		plan.FirewallRule = &FirewallRulePlan{Port: nfsPort}
+		for _, ipRange := range rule.IpRanges {
+			plan.FirewallRule.IPv4CIDRBlocks = append(plan.FirewallRule.IPv4CIDRBlocks, aws.StringValue(ipRange.CidrIp))
+		}
+		for _, ipRange := range rule.Ipv6Ranges {
+			plan.FirewallRule.IPv6CIDRBlocks = append(plan.FirewallRule.IPv6CIDRBlocks, aws.StringValue(ipRange.CidrIpv6))
+		}
+		for _, pair := range rule.UserIdGroupPairs {
+			plan.FirewallRule.SecurityGroupIDs = append(plan.FirewallRule.SecurityGroupIDs, aws.StringValue(pair.GroupId))
+		}
+	}
+
+	klog.V(4).Info("Looking for EFS volume")
+	fs, err := efs.findEFSFileSystem()
+	if err != nil {
+		return nil, err
+	}
+	existingMountTargets := map[string]string{}
+	var existingAccessPoints []string
+	if fs != nil {
+		if aws.StringValue(fs.AvailabilityZoneName) != efs.options.OneZone {
+			return nil, fmt.Errorf("existing filesystem %s has availability zone %q, expected %q", *fs.FileSystemId, aws.StringValue(fs.AvailabilityZoneName), efs.options.OneZone)
+		}
+		plan.FileSystem.Action = PlanActionReuse
+		plan.FileSystem.ID = *fs.FileSystemId
+		efs.resources.efsID = *fs.FileSystemId
+
+		existingMountTargets, err = efs.getMountTargetsBySubnet()
+		if err != nil {
+			return nil, err
+		}
+		aps, err := efs.getAccessPoints()
+		if err != nil {
+			return nil, err
+		}
+		for _, ap := range efs.options.AccessPoints {
+			existingAccessPoints = append(existingAccessPoints, findAccessPoint(aps, ap))
+		}
+	}
+
+	for _, subnet := range efs.subnetIDs {
+		mt := MountTargetPlan{
+			Action:           PlanActionCreate,
+			SubnetID:         subnet,
+			AvailabilityZone: efs.subnetZones[subnet],
+			IPAddressType:    efs.subnetIPAddressTypes[subnet],
+		}
+		if mtID, found := existingMountTargets[subnet]; found {
+			mt.Action = PlanActionReuse
+			mt.ID = mtID
+		}
+		plan.MountTargets = append(plan.MountTargets, mt)
+	}
+
+	for i, ap := range efs.options.AccessPoints {
+		apPlan := AccessPointPlan{
+			Action:      PlanActionCreate,
+			Path:        ap.Path,
+			UID:         ap.UID,
+			GID:         ap.GID,
+			Permissions: ap.Permissions,
+		}
+		if i < len(existingAccessPoints) && existingAccessPoints[i] != "" {
+			apPlan.Action = PlanActionReuse
+			apPlan.ID = existingAccessPoints[i]
+		}
+		plan.AccessPoints = append(plan.AccessPoints, apPlan)
+	}
+	return plan, nil
+}
+
+func writePlan(w io.Writer, plan *Plan) error {
+	content, err := yaml.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
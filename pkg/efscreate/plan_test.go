@@ -0,0 +1,90 @@
+package efscreate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanEFSVolume(t *testing.T) {
+	mutatingCalls := []string{
+		"CreateSecurityGroup", "AuthorizeSecurityGroupIngress", "CreateFileSystem",
+		"PutLifecycleConfiguration", "CreateMountTarget", "CreateAccessPoint",
+	}
+	accessPoints := []AccessPointOptions{{UID: 1000, GID: 1000, Path: "/data", Permissions: "750"}}
+	nodes := newTestNodes("i-1", "i-2", "i-3")
+
+	tests := []struct {
+		name           string
+		existing       bool
+		expectedAction string
+		expectedRule   bool
+	}{
+		{
+			name:           "new cluster",
+			expectedAction: PlanActionCreate,
+			expectedRule:   true,
+		},
+		{
+			name:           "resources from a previous run",
+			existing:       true,
+			expectedAction: PlanActionReuse,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newTestCluster()
+			options := newTestOptions()
+			options.AccessPoints = accessPoints
+			if test.existing {
+				if _, err := newTestEFS(fake, options).CreateEFSVolume(nodes); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			calls := map[string]int{}
+			for _, operation := range mutatingCalls {
+				calls[operation] = fake.calls[operation]
+			}
+
+			plan, err := newTestEFS(fake, options).PlanEFSVolume(nodes)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, operation := range mutatingCalls {
+				if fake.calls[operation] != calls[operation] {
+					t.Errorf("unexpected %s call", operation)
+				}
+			}
+
+			if plan.VPCID != testVPCID || plan.Region != "us-east-1" {
+				t.Errorf("unexpected VPC %s in region %s", plan.VPCID, plan.Region)
+			}
+			if (plan.FirewallRule != nil) != test.expectedRule {
+				t.Errorf("expected firewall rule: %v, got %+v", test.expectedRule, plan.FirewallRule)
+			}
+			if plan.FirewallRule != nil && strings.Join(plan.FirewallRule.IPv4CIDRBlocks, ",") != "10.0.0.0/16" {
+				t.Errorf("unexpected firewall rule %+v", plan.FirewallRule)
+			}
+			actions := []string{plan.SecurityGroup.Action, plan.FileSystem.Action}
+			var zones []string
+			for _, mt := range plan.MountTargets {
+				actions = append(actions, mt.Action)
+				zones = append(zones, mt.AvailabilityZone)
+			}
+			for _, ap := range plan.AccessPoints {
+				actions = append(actions, ap.Action)
+			}
+			if len(actions) != 6 {
+				t.Errorf("expected 6 resources in the plan, got %+v", plan)
+			}
+			for _, action := range actions {
+				if action != test.expectedAction {
+					t.Errorf("expected all resources to %s, got %+v", test.expectedAction, plan)
+					break
+				}
+			}
+			if strings.Join(zones, ",") != "us-east-1a,us-east-1b,us-east-1c" {
+				t.Errorf("unexpected zones of mount targets: %v", zones)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package efscreate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+	"sigs.k8s.io/yaml"
+)
+
+// Report describes the resources of an EFS volume created by create-efs-volume
+// in a machine-readable form.
+type Report struct {
+	FileSystemID    string              `json:"fileSystemID"`
+	FileSystemARN   string              `json:"fileSystemARN"`
+	Region          string              `json:"region"`
+	VPCID           string              `json:"vpcID"`
+	CIDRBlock       string              `json:"cidrBlock"`
+	SecurityGroupID string              `json:"securityGroupID"`
+	MountTargets    []MountTargetReport `json:"mountTargets"`
+	AccessPointIDs  []string            `json:"accessPointIDs,omitempty"`
+	Steps           []StepReport        `json:"steps"`
+}
+
+// MountTargetReport describes a single mount target of the file system.
+type MountTargetReport struct {
+	MountTargetID    string `json:"mountTargetID"`
+	SubnetID         string `json:"subnetID"`
+	AvailabilityZone string `json:"availabilityZone"`
+	IPAddress        string `json:"ipAddress"`
+}
+
+// StepReport records how long a single step of CreateEFSVolume took.
+type StepReport struct {
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// validateReportFile checks that the format of the report can be derived from
+// its file name.
+func validateReportFile(fileName string) error {
+	switch filepath.Ext(fileName) {
+	case ".json", ".yaml", ".yml":
+		return nil
+	default:
+		return fmt.Errorf("unsupported report file %q: expected .json, .yaml or .yml extension", fileName)
+	}
+}
+
+// recordStep stores duration of a step that started at the given time.
+func (efs *EFS) recordStep(name string, start time.Time) {
+	efs.steps = append(efs.steps, StepReport{
+		Name:            name,
+		DurationSeconds: time.Since(start).Seconds(),
+	})
+}
+
+// GetReport describes the file system and its mount targets created or adopted
+// by CreateEFSVolume.
+func (efs *EFS) GetReport() (*Report, error) {
+	efsID := efs.resources.efsID
+	fsResponse, err := efs.efsClient.DescribeFileSystems(&awsefs.DescribeFileSystemsInput{
+		FileSystemId: aws.String(efsID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing filesystem %s: %v", efsID, err)
+	}
+	if len(fsResponse.FileSystems) < 1 {
+		return nil, fmt.Errorf("filesystem %s not found", efsID)
+	}
+
+	report := &Report{
+		FileSystemID:    efsID,
+		FileSystemARN:   aws.StringValue(fsResponse.FileSystems[0].FileSystemArn),
+		Region:          efs.infra.Status.PlatformStatus.AWS.Region,
+		VPCID:           efs.vpcID,
+		CIDRBlock:       efs.cidrBlock,
+		SecurityGroupID: efs.resources.securityGroupID,
+		MountTargets:    []MountTargetReport{},
+		AccessPointIDs:  efs.resources.accessPoints,
+		Steps:           efs.steps,
+	}
+
+	input := &awsefs.DescribeMountTargetsInput{FileSystemId: aws.String(efsID)}
+	for {
+		response, err := efs.efsClient.DescribeMountTargets(input)
+		if err != nil {
+			return nil, fmt.Errorf("error listing mount targets: %v", err)
+		}
+		for _, mt := range response.MountTargets {
+			if aws.StringValue(mt.LifeCycleState) == awsefs.LifeCycleStateDeleting ||
+				aws.StringValue(mt.LifeCycleState) == awsefs.LifeCycleStateDeleted {
+				continue
+			}
+			report.MountTargets = append(report.MountTargets, MountTargetReport{
+				MountTargetID:    aws.StringValue(mt.MountTargetId),
+				SubnetID:         aws.StringValue(mt.SubnetId),
+				AvailabilityZone: aws.StringValue(mt.AvailabilityZoneName),
+				IPAddress:        aws.StringValue(mt.IpAddress),
+			})
+		}
+		if response.NextMarker == nil || len(*response.NextMarker) == 0 {
+			return report, nil
+		}
+		input.Marker = response.NextMarker
+	}
+}
+
+func writeReport(fileName string, report *Report) error {
+	var content []byte
+	var err error
+	if filepath.Ext(fileName) == ".json" {
+		content, err = json.MarshalIndent(report, "", "  ")
+	} else {
+		content, err = yaml.Marshal(report)
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, content, fileMode)
+}
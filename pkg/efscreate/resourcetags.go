@@ -0,0 +1,73 @@
+package efscreate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+)
+
+// getResourceTags returns tags of a resource: user-defined resource tags from
+// the Infrastructure, the Name tag and the cluster ownership tag. The last two
+// can't be overridden by the user.
+func (efs *EFS) getResourceTags(resourceName string) map[string]string {
+	tags := map[string]string{}
+	if platformStatus := efs.infra.Status.PlatformStatus; platformStatus != nil && platformStatus.AWS != nil {
+		for _, tag := range platformStatus.AWS.ResourceTags {
+			tags[tag.Key] = tag.Value
+		}
+	}
+	tags["Name"] = resourceName
+	tags[efs.getClusterTagKey()] = "owned"
+	return tags
+}
+
+func (efs *EFS) getEFSTags(resourceName string) []*awsefs.Tag {
+	tags := efs.getResourceTags(resourceName)
+	var tagList []*awsefs.Tag
+	for _, k := range sortedTagKeys(tags) {
+		tagList = append(tagList, &awsefs.Tag{
+			Key: aws.String(k), Value: aws.String(tags[k]),
+		})
+	}
+	return tagList
+}
+
+func (efs *EFS) getEC2Tags(resourceName string) []*ec2.Tag {
+	tags := efs.getResourceTags(resourceName)
+	var tagList []*ec2.Tag
+	for _, k := range sortedTagKeys(tags) {
+		tagList = append(tagList, &ec2.Tag{
+			Key: aws.String(k), Value: aws.String(tags[k]),
+		})
+	}
+	return tagList
+}
+
+// tagMountTarget tags the network interface of a mount target. EFS does not
+// support tags on mount targets themselves and the interface is deleted
+// together with the mount target.
+func (efs *EFS) tagMountTarget(mtID, networkInterfaceID *string) error {
+	if networkInterfaceID == nil {
+		return nil
+	}
+	input := &ec2.CreateTagsInput{
+		Resources: []*string{networkInterfaceID},
+		Tags:      efs.getEC2Tags(efs.getVolumeName() + "-" + aws.StringValue(mtID)),
+	}
+	if _, err := efs.fsAccountClient.CreateTags(input); err != nil {
+		return fmt.Errorf("error tagging network interface %s of mount target %s: %v", *networkInterfaceID, *mtID, err)
+	}
+	return nil
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	var keys []string
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,75 @@
+package efscreate
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+	v1 "github.com/openshift/api/config/v1"
+)
+
+func TestCreateEFSVolumeResourceTags(t *testing.T) {
+	fake := newTestCluster()
+	options := newTestOptions()
+	options.AccessPoints = []AccessPointOptions{
+		{UID: 1000, GID: 1000, Path: "/data", Permissions: "750"},
+	}
+	efs := newTestEFS(fake, options)
+	efs.infra.Status.PlatformStatus.AWS.ResourceTags = []v1.AWSResourceTag{
+		{Key: "cost-center", Value: "storage"},
+		// The user can't override Name and the ownership tag
+		{Key: "Name", Value: "user-defined"},
+		{Key: efs.getClusterTagKey(), Value: "shared"},
+	}
+	if _, err := efs.CreateEFSVolume(newTestNodes("i-1", "i-2", "i-3")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkTags := func(resource string, tags map[string]string) {
+		t.Helper()
+		expected := map[string]string{
+			"cost-center":          "storage",
+			efs.getClusterTagKey(): "owned",
+		}
+		for k, v := range expected {
+			if tags[k] != v {
+				t.Errorf("expected tag %s=%s on %s, got %v", k, v, resource, tags)
+			}
+		}
+		if tags["Name"] == "user-defined" {
+			t.Errorf("expected Name of %s not overridden by resource tags", resource)
+		}
+	}
+	for id, sg := range fake.securityGroups {
+		checkTags(id, ec2TagMap(sg.Tags))
+	}
+	for id, fs := range fake.fileSystems {
+		checkTags(id, efsTagMap(fs.Tags))
+	}
+	for id, ap := range fake.accessPoints {
+		checkTags(id, efsTagMap(ap.Tags))
+	}
+	if len(fake.networkInterfaceTags) != 3 {
+		t.Errorf("expected tags on 3 mount target network interfaces, got %d", len(fake.networkInterfaceTags))
+	}
+	for id, tags := range fake.networkInterfaceTags {
+		checkTags(id, ec2TagMap(tags))
+	}
+}
+
+func ec2TagMap(tags []*ec2.Tag) map[string]string {
+	result := map[string]string{}
+	for _, tag := range tags {
+		result[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return result
+}
+
+func efsTagMap(tags []*awsefs.Tag) map[string]string {
+	result := map[string]string{}
+	for _, tag := range tags {
+		result[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return result
+}
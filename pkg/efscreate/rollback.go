@@ -0,0 +1,41 @@
+package efscreate
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// undoAction reverts a single step of CreateEFSVolume.
+type undoAction struct {
+	description string
+	undo        func() error
+}
+
+// registerUndo records an action that reverts a resource created by the
+// current run. Adopted resources must not be registered.
+func (efs *EFS) registerUndo(description string, undo func() error) {
+	efs.undoActions = append(efs.undoActions, undoAction{description: description, undo: undo})
+}
+
+// rollback runs all registered undo actions in reverse order. It does not stop
+// at the first failure and returns an error listing all actions that failed.
+func (efs *EFS) rollback() error {
+	var failed []string
+	for i := len(efs.undoActions) - 1; i >= 0; i-- {
+		action := efs.undoActions[i]
+		klog.V(4).Infof("Rolling back: %s", action.description)
+		if err := action.undo(); err != nil {
+			klog.Errorf("error rolling back %s: %v", action.description, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", action.description, err))
+			continue
+		}
+		log("rolled back %s", action.description)
+	}
+	efs.undoActions = nil
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to roll back: [%s]", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package efscreate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// Tag set by the installer on private subnets of the cluster
+	internalELBTagKey = "kubernetes.io/role/internal-elb"
+)
+
+// selectSubnets chooses exactly one subnet per availability zone for mount
+// targets. Subnets given in Options.SubnetIDs are used as they are. Otherwise
+// subnets of the cluster instances are combined with private subnets tagged
+// for the cluster, so that zones without any node get a mount target too.
+func (efs *EFS) selectSubnets(instances []*ec2.Instance) error {
+	if len(efs.options.SubnetIDs) > 0 {
+		return efs.selectExplicitSubnets()
+	}
+
+	// Subnets of the cluster instances take precedence over tagged ones
+	instanceSubnets := map[string]sets.String{}
+	for _, instance := range instances {
+		if instance.SubnetId == nil || instance.Placement == nil {
+			continue
+		}
+		zone := aws.StringValue(instance.Placement.AvailabilityZone)
+		if _, found := instanceSubnets[zone]; !found {
+			instanceSubnets[zone] = sets.NewString()
+		}
+		instanceSubnets[zone].Insert(*instance.SubnetId)
+	}
+
+	taggedSubnets, err := efs.getTaggedPrivateSubnets()
+	if err != nil {
+		return err
+	}
+	zoneSubnets := map[string]sets.String{}
+	for _, subnet := range taggedSubnets {
+		zone := aws.StringValue(subnet.AvailabilityZone)
+		if _, found := zoneSubnets[zone]; !found {
+			zoneSubnets[zone] = sets.NewString()
+		}
+		zoneSubnets[zone].Insert(*subnet.SubnetId)
+	}
+	for zone, subnets := range instanceSubnets {
+		zoneSubnets[zone] = subnets
+	}
+	return efs.setSubnetsByZone(zoneSubnets)
+}
+
+func (efs *EFS) selectExplicitSubnets() error {
+	response, err := efs.fsAccountClient.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		SubnetIds: aws.StringSlice(efs.options.SubnetIDs),
+	})
+	if err != nil {
+		return fmt.Errorf("error listing subnets: %v", err)
+	}
+	zoneSubnets := map[string]sets.String{}
+	for _, subnet := range response.Subnets {
+		if aws.StringValue(subnet.VpcId) != efs.vpcID {
+			return fmt.Errorf("subnet %s is not in the VPC %s of mount targets", *subnet.SubnetId, efs.vpcID)
+		}
+		zone := aws.StringValue(subnet.AvailabilityZone)
+		if _, found := zoneSubnets[zone]; !found {
+			zoneSubnets[zone] = sets.NewString()
+		}
+		zoneSubnets[zone].Insert(*subnet.SubnetId)
+	}
+	return efs.setSubnetsByZone(zoneSubnets)
+}
+
+// getTaggedPrivateSubnets returns private subnets of the cluster VPC tagged
+// with the cluster tag.
+func (efs *EFS) getTaggedPrivateSubnets() ([]*ec2.Subnet, error) {
+	input := &ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(efs.vpcID)},
+			},
+			{
+				Name:   aws.String("tag-key"),
+				Values: []*string{aws.String(efs.getClusterTagKey())},
+			},
+		},
+	}
+	var subnets []*ec2.Subnet
+	for {
+		response, err := efs.fsAccountClient.DescribeSubnets(input)
+		if err != nil {
+			return nil, fmt.Errorf("error listing subnets: %v", err)
+		}
+		for _, subnet := range response.Subnets {
+			for _, tag := range subnet.Tags {
+				if aws.StringValue(tag.Key) == internalELBTagKey {
+					subnets = append(subnets, subnet)
+					break
+				}
+			}
+		}
+		if response.NextToken == nil || len(*response.NextToken) == 0 {
+			return subnets, nil
+		}
+		input.NextToken = response.NextToken
+	}
+}
+
+// setSubnetsByZone stores the subnets to create mount targets in. A file
+// system can have only one mount target per availability zone, so more
+// subnets in a single zone are an error.
+func (efs *EFS) setSubnetsByZone(zoneSubnets map[string]sets.String) error {
+	var collisions []string
+	var subnetIDs []string
+	for zone, subnets := range zoneSubnets {
+		if subnets.Len() > 1 {
+			collisions = append(collisions, fmt.Sprintf("%s: %s", zone, strings.Join(subnets.List(), ", ")))
+			continue
+		}
+		subnet := subnets.List()[0]
+		subnetIDs = append(subnetIDs, subnet)
+		efs.subnetZones[subnet] = zone
+	}
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return fmt.Errorf("only one mount target per availability zone is allowed, found multiple subnets in zones [%s], use --subnet-ids to select one subnet per zone", strings.Join(collisions, "; "))
+	}
+	if len(subnetIDs) == 0 {
+		return fmt.Errorf("no subnets found for mount targets")
+	}
+	sort.Strings(subnetIDs)
+	efs.subnetIDs = subnetIDs
+	return nil
+}
+
+// selectOneZoneSubnet keeps only a single subnet in the given availability
+// zone, a One Zone file system can have only one mount target.
+func (efs *EFS) selectOneZoneSubnet(zone string) error {
+	for _, subnet := range efs.subnetIDs {
+		if efs.subnetZones[subnet] == zone {
+			efs.subnetIDs = []string{subnet}
+			return nil
+		}
+	}
+	return fmt.Errorf("no subnet found in availability zone %s", zone)
+}
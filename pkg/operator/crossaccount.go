@@ -0,0 +1,115 @@
+package operator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Secret with a role in another AWS account, where EFS file systems live.
+	// The driver assumes the role when the Secret is passed to it as the
+	// provisioner secret of a StorageClass.
+	crossAccountSecretName = "aws-efs-cross-account"
+	crossAccountRoleARNKey = "awsRoleArn"
+
+	// StorageClass parameters of the external-provisioner, which passes the
+	// Secret to CreateVolume and DeleteVolume calls of the driver
+	provisionerSecretNameKey      = "csi.storage.k8s.io/provisioner-secret-name"
+	provisionerSecretNamespaceKey = "csi.storage.k8s.io/provisioner-secret-namespace"
+
+	crossAccountSecretCondition = "CrossAccountSecretDegraded"
+
+	crossAccountRoleARNEnvVar = "CROSS_ACCOUNT_ROLE_ARN"
+)
+
+var roleARNRegexp = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:role/[\w+=,.@/-]+$`)
+
+// getCrossAccountParameters returns StorageClass parameters that pass the
+// cross-account Secret to the driver. The Secret is nil when the cross-account
+// mode is not configured.
+func getCrossAccountParameters(secret *corev1.Secret) (map[string]string, error) {
+	if secret == nil {
+		return nil, nil
+	}
+	if _, err := getCrossAccountRoleARN(secret); err != nil {
+		return nil, fmt.Errorf("invalid Secret %s/%s: %v", secret.Namespace, secret.Name, err)
+	}
+	return map[string]string{
+		provisionerSecretNameKey:      secret.Name,
+		provisionerSecretNamespaceKey: secret.Namespace,
+	}, nil
+}
+
+// withCrossAccountRoleHook sets the cross-account role ARN from the Secret as
+// an env. var of the driver container. The Secret hash annotation restarts the
+// controller when the Secret changes. A malformed Secret is reported as an
+// error, which makes the controller Degraded and keeps the current Deployment
+// untouched.
+func withCrossAccountRoleHook(namespace, secretName string, secretInformer corev1informers.SecretInformer) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		secret, err := secretInformer.Lister().Secrets(namespace).Get(secretName)
+		if errors.IsNotFound(err) {
+			// Cross-account mode is not configured
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		roleARN, err := getCrossAccountRoleARN(secret)
+		if err != nil {
+			return fmt.Errorf("invalid Secret %s/%s: %v", namespace, secretName, err)
+		}
+
+		containers := deployment.Spec.Template.Spec.Containers
+		for i := range containers {
+			if containers[i].Name != driverContainerName {
+				continue
+			}
+			klog.V(4).Infof("Using cross-account role %s", roleARN)
+			containers[i].Env = append(containers[i].Env, corev1.EnvVar{
+				Name:  crossAccountRoleARNEnvVar,
+				Value: roleARN,
+			})
+			return nil
+		}
+		return fmt.Errorf("container %s not found in deployment %s", driverContainerName, deployment.Name)
+	}
+}
+
+func getCrossAccountRoleARN(secret *corev1.Secret) (string, error) {
+	value, found := secret.Data[crossAccountRoleARNKey]
+	if !found {
+		return "", fmt.Errorf("key %s not found", crossAccountRoleARNKey)
+	}
+	roleARN := strings.TrimSpace(string(value))
+	if !roleARNRegexp.MatchString(roleARN) {
+		return "", fmt.Errorf("%s %q is not an IAM role ARN", crossAccountRoleARNKey, roleARN)
+	}
+	return roleARN, nil
+}
+
+// crossAccountSecretConditionFn reports the cross-account Secret error, or
+// clears the condition when there is none.
+func crossAccountSecretConditionFn(secretErr error) v1helpers.UpdateStatusFunc {
+	condition := opv1.OperatorCondition{
+		Type:   crossAccountSecretCondition,
+		Status: opv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if secretErr != nil {
+		condition.Status = opv1.ConditionTrue
+		condition.Reason = "InvalidSecret"
+		condition.Message = secretErr.Error()
+	}
+	return v1helpers.UpdateConditionFn(condition)
+}
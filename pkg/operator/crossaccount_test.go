@@ -0,0 +1,160 @@
+package operator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivercontrollerservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testRoleARN = "arn:aws:iam::123456789012:role/efs-cross-account"
+
+func newCrossAccountSecret(data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: crossAccountSecretName, Namespace: testNamespace},
+		Data:       map[string][]byte{},
+	}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+	return secret
+}
+
+func TestGetCrossAccountParameters(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      *corev1.Secret
+		expected    map[string]string
+		expectedErr string
+	}{
+		{
+			name: "no secret",
+		},
+		{
+			name:   "valid role",
+			secret: newCrossAccountSecret(map[string]string{crossAccountRoleARNKey: " " + testRoleARN + "\n"}),
+			expected: map[string]string{
+				provisionerSecretNameKey:      crossAccountSecretName,
+				provisionerSecretNamespaceKey: testNamespace,
+			},
+		},
+		{
+			name:        "missing key",
+			secret:      newCrossAccountSecret(map[string]string{"roleArn": testRoleARN}),
+			expectedErr: "key awsRoleArn not found",
+		},
+		{
+			name:        "not a role",
+			secret:      newCrossAccountSecret(map[string]string{crossAccountRoleARNKey: "arn:aws:iam::123456789012:user/admin"}),
+			expectedErr: "is not an IAM role ARN",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parameters, err := getCrossAccountParameters(test.secret)
+			if test.expectedErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), test.expectedErr)) {
+				t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+			}
+			if !reflect.DeepEqual(parameters, test.expected) {
+				t.Errorf("expected parameters %v, got %v", test.expected, parameters)
+			}
+		})
+	}
+}
+
+func TestWithCrossAccountRoleHook(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      *corev1.Secret
+		expectedEnv []corev1.EnvVar
+		expectedErr string
+	}{
+		{
+			name: "no secret",
+		},
+		{
+			name:        "valid role",
+			secret:      newCrossAccountSecret(map[string]string{crossAccountRoleARNKey: testRoleARN}),
+			expectedEnv: []corev1.EnvVar{{Name: crossAccountRoleARNEnvVar, Value: testRoleARN}},
+		},
+		{
+			name:        "invalid role",
+			secret:      newCrossAccountSecret(map[string]string{crossAccountRoleARNKey: "admin"}),
+			expectedErr: "invalid Secret test-namespace/aws-efs-cross-account",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secretInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+			if test.secret != nil {
+				secretInformer.Informer().GetIndexer().Add(test.secret)
+			}
+			deployment := newCrossAccountDeployment()
+			err := withCrossAccountRoleHook(testNamespace, crossAccountSecretName, secretInformer)(&opv1.OperatorSpec{}, deployment)
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if env := deployment.Spec.Template.Spec.Containers[0].Env; !reflect.DeepEqual(env, test.expectedEnv) {
+				t.Errorf("expected env %v, got %v", test.expectedEnv, env)
+			}
+		})
+	}
+}
+
+func TestCrossAccountRoleChangeRestartsController(t *testing.T) {
+	secretInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+	// The hooks as registered in RunOperator
+	hooks := []dc.DeploymentHookFunc{
+		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(testNamespace, crossAccountSecretName, secretInformer),
+		withCrossAccountRoleHook(testNamespace, crossAccountSecretName, secretInformer),
+	}
+	podAnnotations := func(roleARN string) map[string]string {
+		secretInformer.Informer().GetIndexer().Update(newCrossAccountSecret(map[string]string{crossAccountRoleARNKey: roleARN}))
+		deployment := newCrossAccountDeployment()
+		for _, hook := range hooks {
+			if err := hook(&opv1.OperatorSpec{}, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		return deployment.Spec.Template.Annotations
+	}
+
+	first := podAnnotations(testRoleARN)
+	if len(first) != 1 {
+		t.Fatalf("expected a secret hash annotation, got %v", first)
+	}
+	second := podAnnotations("arn:aws:iam::123456789012:role/other")
+	if reflect.DeepEqual(first, second) {
+		t.Errorf("expected the secret hash annotation to change with the role, got %v", second)
+	}
+}
+
+func newCrossAccountDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-efs-csi-driver-controller"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: driverContainerName}},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,177 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+)
+
+const (
+	// ConfigMap with optional configuration of the driver controller
+	driverConfigMapName = "aws-efs-csi-driver-config"
+
+	deleteAccessPointRootDirKey = "deleteAccessPointRootDir"
+	extraTagsKey                = "extraTags"
+	provisionerTimeoutKey       = "provisionerTimeout"
+	provisionerWorkerThreadsKey = "provisionerWorkerThreads"
+
+	// StorageClass parameters, see storageclass.go
+	fileSystemIDKey   = "fileSystemId"
+	basePathKey       = "basePath"
+	directoryPermsKey = "directoryPerms"
+	uidKey            = "uid"
+	gidKey            = "gid"
+	gidRangeStartKey  = "gidRangeStart"
+	gidRangeEndKey    = "gidRangeEnd"
+
+	// Prefix of the cluster ownership tag set in controller.yaml
+	clusterTagPrefix = "kubernetes.io/cluster/"
+
+	driverContainerName         = "csi-driver"
+	provisionerContainerName    = "csi-provisioner"
+	maxProvisionerWorkerThreads = 100
+)
+
+// driverConfig holds driver controller settings parsed from the ConfigMap.
+// Empty fields keep the defaults from controller.yaml.
+type driverConfig struct {
+	deleteAccessPointRootDir string
+	extraTags                []string
+	provisionerTimeout       string
+	provisionerWorkerThreads string
+	// Parameters of the operator-managed StorageClass, empty when no
+	// file system ID is configured
+	storageClassParameters map[string]string
+}
+
+// withDriverConfigHook rewrites args of the driver controller containers
+// according to the ConfigMap. extraTags are set by withDriverTagsHook. An invalid ConfigMap is reported as an error,
+// which makes the controller Degraded and keeps the current Deployment untouched.
+func withDriverConfigHook(namespace, configMapName string, configMapInformer corev1informers.ConfigMapInformer) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cm, err := configMapInformer.Lister().ConfigMaps(namespace).Get(configMapName)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		config, err := parseDriverConfig(cm)
+		if err != nil {
+			return fmt.Errorf("invalid ConfigMap %s/%s: %v", namespace, configMapName, err)
+		}
+
+		containers := deployment.Spec.Template.Spec.Containers
+		for i := range containers {
+			switch containers[i].Name {
+			case driverContainerName:
+				if config.deleteAccessPointRootDir != "" {
+					setContainerArg(&containers[i], "--delete-access-point-root-dir", config.deleteAccessPointRootDir)
+				}
+			case provisionerContainerName:
+				if config.provisionerTimeout != "" {
+					setContainerArg(&containers[i], "--timeout", config.provisionerTimeout)
+				}
+				if config.provisionerWorkerThreads != "" {
+					setContainerArg(&containers[i], "--worker-threads", config.provisionerWorkerThreads)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func parseDriverConfig(cm *corev1.ConfigMap) (*driverConfig, error) {
+	config := &driverConfig{storageClassParameters: map[string]string{}}
+	var unknown []string
+	for key, value := range cm.Data {
+		value = strings.TrimSpace(value)
+		switch key {
+		case deleteAccessPointRootDirKey:
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be true or false, got %q", key, value)
+			}
+			config.deleteAccessPointRootDir = strconv.FormatBool(enabled)
+		case extraTagsKey:
+			tags, err := parseTags(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			config.extraTags = tags
+		case provisionerTimeoutKey:
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("%s must be a positive duration such as 5m, got %q", key, value)
+			}
+			config.provisionerTimeout = timeout.String()
+		case provisionerWorkerThreadsKey:
+			threads, err := strconv.Atoi(value)
+			if err != nil || threads < 1 || threads > maxProvisionerWorkerThreads {
+				return nil, fmt.Errorf("%s must be a number between 1 and %d, got %q", key, maxProvisionerWorkerThreads, value)
+			}
+			config.provisionerWorkerThreads = strconv.Itoa(threads)
+		case fileSystemIDKey, basePathKey, directoryPermsKey, uidKey, gidKey, gidRangeStartKey, gidRangeEndKey:
+			if err := validateStorageClassParameter(key, value); err != nil {
+				return nil, err
+			}
+			config.storageClassParameters[key] = value
+		default:
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown keys %v, expected %v", unknown,
+			[]string{deleteAccessPointRootDirKey, extraTagsKey, provisionerTimeoutKey, provisionerWorkerThreadsKey,
+				fileSystemIDKey, basePathKey, directoryPermsKey, uidKey, gidKey, gidRangeStartKey, gidRangeEndKey})
+	}
+	if err := validateStorageClassParameters(config.storageClassParameters); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseTags parses space separated <key>:<value> pairs, as accepted by the
+// --tags arg of the driver. Keys must be unique and must not override the
+// cluster ownership tag.
+func parseTags(value string) ([]string, error) {
+	var tags []string
+	keys := map[string]bool{}
+	for _, tag := range strings.Fields(value) {
+		key, _, found := strings.Cut(tag, ":")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid tag %q, expected <key>:<value>", tag)
+		}
+		if strings.HasPrefix(key, clusterTagPrefix) {
+			return nil, fmt.Errorf("tag %q is reserved for the cluster", key)
+		}
+		if keys[key] {
+			return nil, fmt.Errorf("duplicate tag %q", key)
+		}
+		keys[key] = true
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// setContainerArg replaces value of an existing --flag=value arg or adds a new one.
+func setContainerArg(container *corev1.Container, flag, value string) {
+	arg := flag + "=" + value
+	for i := range container.Args {
+		if strings.HasPrefix(container.Args[i], flag+"=") {
+			container.Args[i] = arg
+			return
+		}
+	}
+	container.Args = append(container.Args, arg)
+}
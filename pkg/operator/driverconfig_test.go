@@ -0,0 +1,194 @@
+package operator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseDriverConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        map[string]string
+		expected    *driverConfig
+		expectedErr string
+	}{
+		{
+			name:     "empty",
+			expected: &driverConfig{storageClassParameters: map[string]string{}},
+		},
+		{
+			name: "all driver settings",
+			data: map[string]string{
+				deleteAccessPointRootDirKey: "False",
+				extraTagsKey:                " team:storage  env:prod ",
+				provisionerTimeoutKey:       "90s",
+				provisionerWorkerThreadsKey: "10",
+			},
+			expected: &driverConfig{
+				deleteAccessPointRootDir: "false",
+				extraTags:                []string{"team:storage", "env:prod"},
+				provisionerTimeout:       "1m30s",
+				provisionerWorkerThreads: "10",
+				storageClassParameters:   map[string]string{},
+			},
+		},
+		{
+			name: "storage class parameters",
+			data: map[string]string{
+				fileSystemIDKey:   "fs-0123456789abcdef0",
+				basePathKey:       "/data",
+				gidRangeStartKey:  "1000",
+				gidRangeEndKey:    "2000",
+				directoryPermsKey: "0750",
+			},
+			expected: &driverConfig{
+				storageClassParameters: map[string]string{
+					fileSystemIDKey:   "fs-0123456789abcdef0",
+					basePathKey:       "/data",
+					gidRangeStartKey:  "1000",
+					gidRangeEndKey:    "2000",
+					directoryPermsKey: "0750",
+				},
+			},
+		},
+		{
+			name:        "invalid bool",
+			data:        map[string]string{deleteAccessPointRootDirKey: "yes please"},
+			expectedErr: "deleteAccessPointRootDir must be true or false",
+		},
+		{
+			name:        "invalid timeout",
+			data:        map[string]string{provisionerTimeoutKey: "-5m"},
+			expectedErr: "provisionerTimeout must be a positive duration",
+		},
+		{
+			name:        "too many worker threads",
+			data:        map[string]string{provisionerWorkerThreadsKey: "101"},
+			expectedErr: "provisionerWorkerThreads must be a number between 1 and 100",
+		},
+		{
+			name:        "invalid tag",
+			data:        map[string]string{extraTagsKey: "team"},
+			expectedErr: `invalid tag "team"`,
+		},
+		{
+			name:        "duplicate tag",
+			data:        map[string]string{extraTagsKey: "team:storage team:network"},
+			expectedErr: `duplicate tag "team"`,
+		},
+		{
+			name:        "cluster tag",
+			data:        map[string]string{extraTagsKey: "kubernetes.io/cluster/test:shared"},
+			expectedErr: `tag "kubernetes.io/cluster/test" is reserved for the cluster`,
+		},
+		{
+			name:        "unknown key",
+			data:        map[string]string{"workerThreads": "10"},
+			expectedErr: "unknown keys [workerThreads]",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := parseDriverConfig(newDriverConfigMap(test.data))
+			if test.expectedErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(config, test.expected) {
+				t.Errorf("expected config %+v, got %+v", test.expected, config)
+			}
+		})
+	}
+}
+
+func TestWithDriverConfigHook(t *testing.T) {
+	newDeployment := func() *appsv1.Deployment {
+		return &appsv1.Deployment{
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Name: driverContainerName,
+								Args: []string{"--delete-access-point-root-dir=true", "--tags=kubernetes.io/cluster/test:owned"},
+							},
+							{
+								Name: provisionerContainerName,
+								Args: []string{"--timeout=5m", "--worker-threads=1"},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name                string
+		configMap           *corev1.ConfigMap
+		expectedDriverArgs  []string
+		expectedProvisioner []string
+		expectedErr         string
+	}{
+		{
+			name:                "no ConfigMap",
+			expectedDriverArgs:  []string{"--delete-access-point-root-dir=true", "--tags=kubernetes.io/cluster/test:owned"},
+			expectedProvisioner: []string{"--timeout=5m", "--worker-threads=1"},
+		},
+		{
+			name: "overrides",
+			configMap: newDriverConfigMap(map[string]string{
+				deleteAccessPointRootDirKey: "false",
+				extraTagsKey:                "team:storage",
+				provisionerTimeoutKey:       "10m",
+				provisionerWorkerThreadsKey: "20",
+			}),
+			// extraTags are set by withDriverTagsHook
+			expectedDriverArgs:  []string{"--delete-access-point-root-dir=false", "--tags=kubernetes.io/cluster/test:owned"},
+			expectedProvisioner: []string{"--timeout=10m0s", "--worker-threads=20"},
+		},
+		{
+			name:        "invalid ConfigMap",
+			configMap:   newDriverConfigMap(map[string]string{provisionerWorkerThreadsKey: "0"}),
+			expectedErr: "invalid ConfigMap test-namespace/aws-efs-csi-driver-config",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configMapInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().ConfigMaps()
+			if test.configMap != nil {
+				configMapInformer.Informer().GetIndexer().Add(test.configMap)
+			}
+			deployment := newDeployment()
+			hook := withDriverConfigHook(testNamespace, driverConfigMapName, configMapInformer)
+			err := hook(&opv1.OperatorSpec{}, deployment)
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			containers := deployment.Spec.Template.Spec.Containers
+			if !reflect.DeepEqual(containers[0].Args, test.expectedDriverArgs) {
+				t.Errorf("expected driver args %v, got %v", test.expectedDriverArgs, containers[0].Args)
+			}
+			if !reflect.DeepEqual(containers[1].Args, test.expectedProvisioner) {
+				t.Errorf("expected provisioner args %v, got %v", test.expectedProvisioner, containers[1].Args)
+			}
+		})
+	}
+}
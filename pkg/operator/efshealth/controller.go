@@ -0,0 +1,481 @@
+package efshealth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+	opv1 "github.com/openshift/api/operator/v1"
+	configv1informers "github.com/openshift/client-go/config/informers/externalversions/config/v1"
+	configv1listers "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	storagev1informers "k8s.io/client-go/informers/storage/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	storagev1listers "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/aws-efs-csi-driver-operator/pkg/awsutil"
+)
+
+const (
+	FileSystemAvailableCondition = "EFSFileSystemAvailable"
+	MountTargetsMissingCondition = "EFSMountTargetsMissing"
+
+	efsProvisioner        = "efs.csi.aws.com"
+	fileSystemIDParameter = "fileSystemId"
+	crossAccountParameter = "crossaccount"
+	// A provisioner secret with a role makes the driver assume the role in
+	// another account, see pkg/operator/crossaccount.go
+	provisionerSecretNameParameter      = "csi.storage.k8s.io/provisioner-secret-name"
+	provisionerSecretNamespaceParameter = "csi.storage.k8s.io/provisioner-secret-namespace"
+	crossAccountRoleARNKey              = "awsRoleArn"
+	infrastructureName    = "cluster"
+	roleSessionName       = "aws-efs-csi-driver-operator"
+
+	// Each sync calls the AWS API, don't do it more often
+	resyncInterval = 5 * time.Minute
+
+	controlPlaneNodeLabel = "node-role.kubernetes.io/control-plane"
+	masterNodeLabel       = "node-role.kubernetes.io/master"
+	workerNodeLabel       = "node-role.kubernetes.io/worker"
+)
+
+// efsAPI is the subset of the EFS API used by the controller.
+type efsAPI interface {
+	DescribeFileSystems(*awsefs.DescribeFileSystemsInput) (*awsefs.DescribeFileSystemsOutput, error)
+	DescribeMountTargets(*awsefs.DescribeMountTargetsInput) (*awsefs.DescribeMountTargetsOutput, error)
+}
+
+// fileSystemStatus is the result of a check of a single file system.
+type fileSystemStatus struct {
+	id             string
+	storageClasses []string
+	// Empty when the file system is available
+	unavailableReason string
+	// Zones with nodes, but without an available mount target, mapped to
+	// names of the nodes
+	uncoveredZones map[string][]string
+	// Error of the AWS API, the file system could not be checked
+	checkErr error
+}
+
+// EFSHealthController checks that EFS file systems referenced by StorageClasses
+// of the driver exist, are available and have a mount target in every zone
+// with nodes. The result is reported as EFSFileSystemAvailable and
+// EFSMountTargetsMissing conditions of the ClusterCSIDriver. AWS is called
+// with the operator's own credentials.
+type EFSHealthController struct {
+	operatorClient     v1helpers.OperatorClient
+	namespace          string
+	secretName         string
+	secretLister       corev1listers.SecretLister
+	storageClassLister storagev1listers.StorageClassLister
+	nodeLister         corev1listers.NodeLister
+	infraLister        configv1listers.InfrastructureLister
+
+	// AWS client and the version of the credentials it was created with
+	client        efsAPI
+	clientVersion string
+	// Uncovered zones reported in the last sync, keyed by file system ID
+	// and zone, mapped to names of the nodes there. An event is emitted
+	// only when they change.
+	reportedZones map[string]string
+}
+
+func NewEFSHealthController(
+	name string,
+	namespace string,
+	secretName string,
+	operatorClient v1helpers.OperatorClient,
+	secretInformer corev1informers.SecretInformer,
+	storageClassInformer storagev1informers.StorageClassInformer,
+	nodeInformer corev1informers.NodeInformer,
+	infraInformer configv1informers.InfrastructureInformer,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &EFSHealthController{
+		operatorClient:     operatorClient,
+		namespace:          namespace,
+		secretName:         secretName,
+		secretLister:       secretInformer.Lister(),
+		storageClassLister: storageClassInformer.Lister(),
+		nodeLister:         nodeInformer.Lister(),
+		infraLister:        infraInformer.Lister(),
+	}
+	// Only worker nodes are watched, control-plane nodes are not checked for
+	// mount targets
+	return factory.New().
+		WithSyncDegradedOnError(operatorClient).
+		WithInformers(
+			operatorClient.Informer(),
+			storageClassInformer.Informer(),
+			infraInformer.Informer(),
+		).
+		WithFilteredEventsInformers(factory.NamesFilter(secretName), secretInformer.Informer()).
+		WithFilteredEventsInformers(workerNodeFilter, nodeInformer.Informer()).
+		WithSync(c.sync).
+		ResyncEvery(resyncInterval).
+		ToController(name, recorder.WithComponentSuffix("efs-health-controller"))
+}
+
+func (c *EFSHealthController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	fileSystems, err := c.getFileSystems()
+	if err != nil {
+		return err
+	}
+	if len(fileSystems) == 0 {
+		updateMetrics(nil)
+		return c.updateConditions(ctx, nil)
+	}
+
+	client, err := c.getClient()
+	if err != nil {
+		return err
+	}
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	nodeZones := getNodeZones(nodes)
+
+	var statuses []*fileSystemStatus
+	for _, fsID := range sortedKeys(fileSystems) {
+		status := &fileSystemStatus{
+			id:             fsID,
+			storageClasses: fileSystems[fsID],
+		}
+		// An AWS error of one file system must not hide the status of the others
+		if err := checkFileSystem(client, status, nodeZones); err != nil {
+			klog.Warningf("Failed to check EFS file system %s: %v", fsID, err)
+			status.checkErr = err
+		}
+		statuses = append(statuses, status)
+	}
+	updateMetrics(statuses)
+	c.reportUncoveredNodes(syncCtx.Recorder(), statuses)
+	return c.updateConditions(ctx, statuses)
+}
+
+// reportUncoveredNodes emits a warning for each zone with nodes that can't
+// mount a file system, pods on them would hang in NFS mount. A zone is
+// reported again only when its nodes change or after it was covered.
+func (c *EFSHealthController) reportUncoveredNodes(recorder events.Recorder, statuses []*fileSystemStatus) {
+	reported := map[string]string{}
+	for _, status := range statuses {
+		for _, zone := range sortedKeys(status.uncoveredZones) {
+			key := status.id + "/" + zone
+			nodes := strings.Join(status.uncoveredZones[zone], ", ")
+			reported[key] = nodes
+			if c.reportedZones[key] == nodes {
+				continue
+			}
+			recorder.Warningf("NodesWithoutMountTarget",
+				"EFS file system %s of StorageClass %s has no available mount target in zone %s, volumes can't be mounted on nodes %s",
+				status.id, strings.Join(status.storageClasses, ", "), zone, nodes)
+		}
+	}
+	c.reportedZones = reported
+}
+
+// getFileSystems returns IDs of file systems referenced by StorageClasses of
+// the driver, mapped to names of the StorageClasses. File systems in other
+// AWS accounts can't be checked with the operator credentials and are skipped.
+func (c *EFSHealthController) getFileSystems() (map[string][]string, error) {
+	storageClasses, err := c.storageClassLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	fileSystems := map[string][]string{}
+	for _, sc := range storageClasses {
+		if sc.Provisioner != efsProvisioner {
+			continue
+		}
+		fsID := sc.Parameters[fileSystemIDParameter]
+		if fsID == "" {
+			continue
+		}
+		crossAccount, err := c.isCrossAccount(sc)
+		if err != nil {
+			return nil, err
+		}
+		if crossAccount {
+			klog.V(4).Infof("Skipping file system %s of StorageClass %s in another account", fsID, sc.Name)
+			continue
+		}
+		fileSystems[fsID] = append(fileSystems[fsID], sc.Name)
+	}
+	for _, names := range fileSystems {
+		sort.Strings(names)
+	}
+	return fileSystems, nil
+}
+
+// isCrossAccount checks whether the driver accesses the file system of the
+// StorageClass in another AWS account, either with the crossaccount parameter
+// or with a provisioner secret that holds a role. Only Secrets in the operator
+// namespace are visible to the controller, a file system with a provisioner
+// secret elsewhere is checked with the operator credentials.
+func (c *EFSHealthController) isCrossAccount(sc *storagev1.StorageClass) (bool, error) {
+	if sc.Parameters[crossAccountParameter] == "true" {
+		return true, nil
+	}
+	name := sc.Parameters[provisionerSecretNameParameter]
+	namespace := sc.Parameters[provisionerSecretNamespaceParameter]
+	if name == "" || namespace == "" {
+		return false, nil
+	}
+	secret, err := c.secretLister.Secrets(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, found := secret.Data[crossAccountRoleARNKey]
+	return found, nil
+}
+
+// getClient returns an EFS client with credentials of the operator. The client
+// is re-created when the credentials Secret changes. With STS, the token of
+// the operator service account projected into the operator pod is used.
+func (c *EFSHealthController) getClient() (efsAPI, error) {
+	secret, err := c.secretLister.Secrets(c.namespace).Get(c.secretName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting AWS credentials: %v", err)
+	}
+	infra, err := c.infraLister.Get(infrastructureName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting infrastructure %s: %v", infrastructureName, err)
+	}
+	if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.AWS == nil {
+		return nil, fmt.Errorf("infrastructure %s has no AWS platform status", infrastructureName)
+	}
+	region := infra.Status.PlatformStatus.AWS.Region
+
+	version := secret.ResourceVersion + "/" + region
+	if c.client != nil && c.clientVersion == version {
+		return c.client, nil
+	}
+	creds, err := awsutil.CredentialsFromSecret(secret, region, roleSessionName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error loading AWS credentials from secret %s/%s: %v", c.namespace, c.secretName, err)
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Credentials: creds,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating AWS session: %v", err)
+	}
+	c.client = awsefs.New(sess)
+	c.clientVersion = version
+	return c.client, nil
+}
+
+// checkFileSystem fills availability and mount target coverage of the file
+// system. Only errors of the AWS API are returned, a missing file system is
+// reported in the status.
+func checkFileSystem(client efsAPI, status *fileSystemStatus, nodeZones map[string][]string) error {
+	response, err := client.DescribeFileSystems(&awsefs.DescribeFileSystemsInput{
+		FileSystemId: aws.String(status.id),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == awsefs.ErrCodeFileSystemNotFound {
+			status.unavailableReason = "not found"
+			return nil
+		}
+		return fmt.Errorf("error describing file system %s: %v", status.id, err)
+	}
+	if len(response.FileSystems) == 0 {
+		status.unavailableReason = "not found"
+		return nil
+	}
+	fs := response.FileSystems[0]
+	if state := aws.StringValue(fs.LifeCycleState); state != awsefs.LifeCycleStateAvailable {
+		status.unavailableReason = fmt.Sprintf("is %s", state)
+		return nil
+	}
+	if fs.AvailabilityZoneName != nil {
+		// One Zone file system, its StorageClasses are expected to allow only
+		// nodes in that zone
+		return nil
+	}
+
+	mountTargets, err := describeMountTargets(client, status.id)
+	if err != nil {
+		return fmt.Errorf("error describing mount targets of file system %s: %v", status.id, err)
+	}
+	coveredZones := map[string]bool{}
+	for _, mt := range mountTargets {
+		if aws.StringValue(mt.LifeCycleState) == awsefs.LifeCycleStateAvailable {
+			coveredZones[aws.StringValue(mt.AvailabilityZoneName)] = true
+		}
+	}
+	for zone, nodes := range nodeZones {
+		if coveredZones[zone] {
+			continue
+		}
+		if status.uncoveredZones == nil {
+			status.uncoveredZones = map[string][]string{}
+		}
+		status.uncoveredZones[zone] = nodes
+	}
+	return nil
+}
+
+func (c *EFSHealthController) updateConditions(ctx context.Context, statuses []*fileSystemStatus) error {
+	availableCondition := opv1.OperatorCondition{
+		Type:   FileSystemAvailableCondition,
+		Status: opv1.ConditionTrue,
+	}
+	missingCondition := opv1.OperatorCondition{
+		Type:   MountTargetsMissingCondition,
+		Status: opv1.ConditionFalse,
+	}
+
+	var unavailable, uncovered, failed []string
+	for _, status := range statuses {
+		if status.checkErr != nil {
+			failed = append(failed, status.checkErr.Error())
+		}
+		if status.unavailableReason != "" {
+			unavailable = append(unavailable, fmt.Sprintf("file system %s of StorageClass %s %s",
+				status.id, strings.Join(status.storageClasses, ", "), status.unavailableReason))
+		}
+		for _, zone := range sortedKeys(status.uncoveredZones) {
+			uncovered = append(uncovered, fmt.Sprintf("file system %s has no mount target in zone %s with nodes %s",
+				status.id, zone, strings.Join(status.uncoveredZones[zone], ", ")))
+		}
+	}
+
+	switch {
+	case len(statuses) == 0:
+		availableCondition.Reason = "NoFileSystems"
+		availableCondition.Message = "No StorageClass references an EFS file system"
+	case len(unavailable) > 0:
+		availableCondition.Status = opv1.ConditionFalse
+		availableCondition.Reason = "FileSystemUnavailable"
+		availableCondition.Message = strings.Join(append(unavailable, failed...), "; ")
+	case len(failed) > 0:
+		availableCondition.Status = opv1.ConditionUnknown
+		availableCondition.Reason = "CheckFailed"
+		availableCondition.Message = strings.Join(failed, "; ")
+	default:
+		availableCondition.Reason = "AsExpected"
+		availableCondition.Message = fmt.Sprintf("All %d file systems are available", len(statuses))
+	}
+	switch {
+	case len(uncovered) > 0:
+		missingCondition.Status = opv1.ConditionTrue
+		missingCondition.Reason = "MountTargetsMissing"
+		missingCondition.Message = strings.Join(append(uncovered, failed...), "; ")
+	case len(failed) > 0:
+		missingCondition.Status = opv1.ConditionUnknown
+		missingCondition.Reason = "CheckFailed"
+		missingCondition.Message = strings.Join(failed, "; ")
+	default:
+		missingCondition.Reason = "AsExpected"
+	}
+
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient,
+		v1helpers.UpdateConditionFn(availableCondition),
+		v1helpers.UpdateConditionFn(missingCondition),
+	)
+	return err
+}
+
+// describeMountTargets returns all mount targets of the file system, the API
+// returns them in pages.
+func describeMountTargets(client efsAPI, fsID string) ([]*awsefs.MountTargetDescription, error) {
+	var mountTargets []*awsefs.MountTargetDescription
+	input := &awsefs.DescribeMountTargetsInput{FileSystemId: aws.String(fsID)}
+	for {
+		response, err := client.DescribeMountTargets(input)
+		if err != nil {
+			return nil, err
+		}
+		mountTargets = append(mountTargets, response.MountTargets...)
+		if aws.StringValue(response.NextMarker) == "" {
+			return mountTargets, nil
+		}
+		input.Marker = response.NextMarker
+	}
+}
+
+// getNodeZones returns zones of the nodes, mapped to sorted node names.
+// Control-plane nodes don't run user workloads and are skipped, unless they're
+// workers too.
+func getNodeZones(nodes []*corev1.Node) map[string][]string {
+	zones := map[string][]string{}
+	for _, node := range nodes {
+		if isControlPlaneOnly(node) {
+			continue
+		}
+		zone := getNodeZone(node)
+		if zone == "" {
+			klog.V(4).Infof("Cannot find zone of node %s", node.Name)
+			continue
+		}
+		zones[zone] = append(zones[zone], node.Name)
+	}
+	for _, names := range zones {
+		sort.Strings(names)
+	}
+	return zones
+}
+
+// workerNodeFilter passes events of nodes counted by getNodeZones.
+func workerNodeFilter(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*corev1.Node)
+	return ok && !isControlPlaneOnly(node)
+}
+
+func isControlPlaneOnly(node *corev1.Node) bool {
+	_, controlPlane := node.Labels[controlPlaneNodeLabel]
+	_, master := node.Labels[masterNodeLabel]
+	_, worker := node.Labels[workerNodeLabel]
+	return (controlPlane || master) && !worker
+}
+
+// getNodeZone returns the zone from providerID of the node, or from the zone
+// label when the providerID has no zone.
+func getNodeZone(node *corev1.Node) string {
+	if zone, _ := awsutil.ParseProviderID(node.Spec.ProviderID); zone != "" {
+		return zone
+	}
+	return node.Labels[corev1.LabelTopologyZone]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,427 @@
+package efshealth
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsefs "github.com/aws/aws-sdk-go/service/efs"
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	configv1listers "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	storagev1listers "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	testNamespace  = "test-namespace"
+	testSecretName = "aws-efs-cloud-credentials"
+	testRegion     = "us-east-1"
+)
+
+// fakeEFS returns file systems and their mount targets from memory.
+type fakeEFS struct {
+	// file system ID -> life cycle state
+	fileSystems map[string]string
+	// file system ID -> zones with an available mount target
+	mountTargets map[string][]string
+	// file system ID -> error of any call
+	errors map[string]error
+}
+
+func (f *fakeEFS) DescribeFileSystems(input *awsefs.DescribeFileSystemsInput) (*awsefs.DescribeFileSystemsOutput, error) {
+	id := aws.StringValue(input.FileSystemId)
+	if err := f.errors[id]; err != nil {
+		return nil, err
+	}
+	state, found := f.fileSystems[id]
+	if !found {
+		return nil, awserr.New(awsefs.ErrCodeFileSystemNotFound, "File system '"+id+"' does not exist.", nil)
+	}
+	return &awsefs.DescribeFileSystemsOutput{
+		FileSystems: []*awsefs.FileSystemDescription{{FileSystemId: aws.String(id), LifeCycleState: aws.String(state)}},
+	}, nil
+}
+
+// DescribeMountTargets returns one mount target per page, the marker is the
+// index of the next one.
+func (f *fakeEFS) DescribeMountTargets(input *awsefs.DescribeMountTargetsInput) (*awsefs.DescribeMountTargetsOutput, error) {
+	id := aws.StringValue(input.FileSystemId)
+	zones := f.mountTargets[id]
+	output := &awsefs.DescribeMountTargetsOutput{}
+	if len(zones) == 0 {
+		return output, nil
+	}
+	i := 0
+	if input.Marker != nil {
+		var err error
+		if i, err = strconv.Atoi(*input.Marker); err != nil || i >= len(zones) {
+			return nil, awserr.New(awsefs.ErrCodeBadRequest, "invalid marker "+*input.Marker, nil)
+		}
+	}
+	output.MountTargets = []*awsefs.MountTargetDescription{{
+		AvailabilityZoneName: aws.String(zones[i]),
+		LifeCycleState:       aws.String(awsefs.LifeCycleStateAvailable),
+	}}
+	if i+1 < len(zones) {
+		output.NextMarker = aws.String(strconv.Itoa(i + 1))
+	}
+	return output, nil
+}
+
+func newStorageClass(name, fsID string) *storagev1.StorageClass {
+	return &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: name},
+		Provisioner: efsProvisioner,
+		Parameters:  map[string]string{fileSystemIDParameter: fsID},
+	}
+}
+
+// withProvisionerSecret sets the provisioner secret of the StorageClass.
+func withProvisionerSecret(sc *storagev1.StorageClass, namespace, name string) *storagev1.StorageClass {
+	sc.Parameters[provisionerSecretNameParameter] = name
+	sc.Parameters[provisionerSecretNamespaceParameter] = namespace
+	return sc
+}
+
+func newNode(name, zone string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{ProviderID: fmt.Sprintf("aws:///%s/i-%s", zone, name)},
+	}
+}
+
+// newTestController returns the controller with listers that contain the
+// given objects and the fake EFS client.
+func newTestController(client efsAPI, objects ...runtime.Object) (*EFSHealthController, v1helpers.OperatorClient) {
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	storageClasses := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nodes := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	infras := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: testSecretName, Namespace: testNamespace, ResourceVersion: "1"}}
+	secrets.Add(secret)
+	infras.Add(&configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+		Status: configv1.InfrastructureStatus{
+			PlatformStatus: &configv1.PlatformStatus{AWS: &configv1.AWSPlatformStatus{Region: testRegion}},
+		},
+	})
+	for _, obj := range objects {
+		switch obj.(type) {
+		case *storagev1.StorageClass:
+			storageClasses.Add(obj)
+		case *corev1.Node:
+			nodes.Add(obj)
+		case *corev1.Secret:
+			secrets.Add(obj)
+		}
+	}
+	operatorClient := v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil)
+	return &EFSHealthController{
+		operatorClient:     operatorClient,
+		namespace:          testNamespace,
+		secretName:         testSecretName,
+		secretLister:       corev1listers.NewSecretLister(secrets),
+		storageClassLister: storagev1listers.NewStorageClassLister(storageClasses),
+		nodeLister:         corev1listers.NewNodeLister(nodes),
+		infraLister:        configv1listers.NewInfrastructureLister(infras),
+		// The credentials of the Secret are never loaded
+		client:        client,
+		clientVersion: secret.ResourceVersion + "/" + testRegion,
+	}, operatorClient
+}
+
+func TestSync(t *testing.T) {
+	client := &fakeEFS{
+		fileSystems: map[string]string{
+			"fs-1": awsefs.LifeCycleStateAvailable,
+			"fs-2": awsefs.LifeCycleStateAvailable,
+			"fs-3": awsefs.LifeCycleStateDeleting,
+		},
+		mountTargets: map[string][]string{
+			"fs-1": {"us-east-1a", "us-east-1b"},
+			"fs-2": {"us-east-1a"},
+		},
+		errors: map[string]error{
+			"fs-error": awserr.New("AccessDeniedException", "not authorized", nil),
+		},
+	}
+	nodes := []runtime.Object{newNode("a", "us-east-1a"), newNode("b", "us-east-1b")}
+	crossAccountSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-efs-cross-account", Namespace: testNamespace},
+		Data:       map[string][]byte{crossAccountRoleARNKey: []byte("arn:aws:iam::222222222222:role/efs")},
+	}
+	otherSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: testNamespace},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		// expected results
+		expectedAvailable       opv1.ConditionStatus
+		expectedAvailableReason string
+		expectedMissing         opv1.ConditionStatus
+		expectedMissingReason   string
+		// substrings of the messages
+		expectedAvailableMessage []string
+		expectedMissingMessage   []string
+	}{
+		{
+			name:                    "no file systems",
+			objects:                 append([]runtime.Object{newStorageClass("other", "")}, nodes...),
+			expectedAvailable:       opv1.ConditionTrue,
+			expectedAvailableReason: "NoFileSystems",
+			expectedMissing:         opv1.ConditionFalse,
+			expectedMissingReason:   "AsExpected",
+		},
+		{
+			name: "cross-account file systems are skipped",
+			objects: append([]runtime.Object{
+				newStorageClass("efs", "fs-1"),
+				withProvisionerSecret(newStorageClass("cross", "fs-error"), testNamespace, crossAccountSecret.Name),
+				crossAccountSecret,
+			}, nodes...),
+			expectedAvailable:        opv1.ConditionTrue,
+			expectedAvailableReason:  "AsExpected",
+			expectedAvailableMessage: []string{"All 1 file systems are available"},
+			expectedMissing:          opv1.ConditionFalse,
+			expectedMissingReason:    "AsExpected",
+		},
+		{
+			name: "provisioner secret without a role",
+			objects: append([]runtime.Object{
+				withProvisionerSecret(newStorageClass("efs", "fs-error"), testNamespace, otherSecret.Name),
+				otherSecret,
+			}, nodes...),
+			expectedAvailable:        opv1.ConditionUnknown,
+			expectedAvailableReason:  "CheckFailed",
+			expectedAvailableMessage: []string{"error describing file system fs-error"},
+			expectedMissing:          opv1.ConditionUnknown,
+			expectedMissingReason:    "CheckFailed",
+		},
+		{
+			name:                    "healthy file system",
+			objects:                 append([]runtime.Object{newStorageClass("efs", "fs-1")}, nodes...),
+			expectedAvailable:       opv1.ConditionTrue,
+			expectedAvailableReason: "AsExpected",
+			expectedMissing:         opv1.ConditionFalse,
+			expectedMissingReason:   "AsExpected",
+		},
+		{
+			name:                     "missing mount target",
+			objects:                  append([]runtime.Object{newStorageClass("efs", "fs-2")}, nodes...),
+			expectedAvailable:        opv1.ConditionTrue,
+			expectedAvailableReason:  "AsExpected",
+			expectedMissing:          opv1.ConditionTrue,
+			expectedMissingReason:    "MountTargetsMissing",
+			expectedMissingMessage:   []string{"file system fs-2 has no mount target in zone us-east-1b with nodes b"},
+			expectedAvailableMessage: []string{"All 1 file systems are available"},
+		},
+		{
+			name:                     "unavailable file systems",
+			objects:                  append([]runtime.Object{newStorageClass("deleting", "fs-3"), newStorageClass("missing", "fs-4")}, nodes...),
+			expectedAvailable:        opv1.ConditionFalse,
+			expectedAvailableReason:  "FileSystemUnavailable",
+			expectedAvailableMessage: []string{"file system fs-3 of StorageClass deleting is deleting", "file system fs-4 of StorageClass missing not found"},
+			expectedMissing:          opv1.ConditionFalse,
+			expectedMissingReason:    "AsExpected",
+		},
+		{
+			name:                     "AWS error",
+			objects:                  append([]runtime.Object{newStorageClass("efs", "fs-1"), newStorageClass("denied", "fs-error")}, nodes...),
+			expectedAvailable:        opv1.ConditionUnknown,
+			expectedAvailableReason:  "CheckFailed",
+			expectedAvailableMessage: []string{"error describing file system fs-error", "AccessDeniedException"},
+			expectedMissing:          opv1.ConditionUnknown,
+			expectedMissingReason:    "CheckFailed",
+			expectedMissingMessage:   []string{"error describing file system fs-error"},
+		},
+		{
+			name:                     "AWS error does not hide other file systems",
+			objects:                  append([]runtime.Object{newStorageClass("efs", "fs-2"), newStorageClass("denied", "fs-error")}, nodes...),
+			expectedAvailable:        opv1.ConditionUnknown,
+			expectedAvailableReason:  "CheckFailed",
+			expectedAvailableMessage: []string{"error describing file system fs-error"},
+			expectedMissing:          opv1.ConditionTrue,
+			expectedMissingReason:    "MountTargetsMissing",
+			expectedMissingMessage:   []string{"file system fs-2 has no mount target in zone us-east-1b", "error describing file system fs-error"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, operatorClient := newTestController(client, test.objects...)
+			syncCtx := factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))
+			if err := c.sync(context.TODO(), syncCtx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, status, _, err := operatorClient.GetOperatorState()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkCondition(t, status, FileSystemAvailableCondition, test.expectedAvailable, test.expectedAvailableReason, test.expectedAvailableMessage)
+			checkCondition(t, status, MountTargetsMissingCondition, test.expectedMissing, test.expectedMissingReason, test.expectedMissingMessage)
+		})
+	}
+}
+
+func checkCondition(t *testing.T, status *opv1.OperatorStatus, conditionType string, expectedStatus opv1.ConditionStatus, expectedReason string, expectedMessage []string) {
+	t.Helper()
+	condition := v1helpers.FindOperatorCondition(status.Conditions, conditionType)
+	if condition == nil {
+		t.Fatalf("condition %s not found", conditionType)
+	}
+	if condition.Status != expectedStatus || condition.Reason != expectedReason {
+		t.Errorf("expected %s condition %s with reason %s, got %s with reason %s: %s",
+			conditionType, expectedStatus, expectedReason, condition.Status, condition.Reason, condition.Message)
+	}
+	for _, message := range expectedMessage {
+		if !strings.Contains(condition.Message, message) {
+			t.Errorf("expected %s condition message to contain %q, got %q", conditionType, message, condition.Message)
+		}
+	}
+}
+
+func TestGetNodeZones(t *testing.T) {
+	master := newNode("master", "us-east-1c")
+	master.Labels = map[string]string{masterNodeLabel: ""}
+	controlPlane := newNode("control-plane", "us-east-1c")
+	controlPlane.Labels = map[string]string{controlPlaneNodeLabel: ""}
+	schedulableMaster := newNode("schedulable-master", "us-east-1b")
+	schedulableMaster.Labels = map[string]string{masterNodeLabel: "", workerNodeLabel: ""}
+	labeled := newNode("labeled", "")
+	labeled.Spec.ProviderID = "i-labeled"
+	labeled.Labels = map[string]string{corev1.LabelTopologyZone: "us-east-1d"}
+	unknown := newNode("unknown", "")
+	unknown.Spec.ProviderID = ""
+
+	zones := getNodeZones([]*corev1.Node{
+		newNode("b", "us-east-1a"), newNode("a", "us-east-1a"), master, controlPlane, schedulableMaster, labeled, unknown,
+	})
+	expected := map[string][]string{
+		"us-east-1a": {"a", "b"},
+		"us-east-1b": {"schedulable-master"},
+		"us-east-1d": {"labeled"},
+	}
+	if !reflect.DeepEqual(zones, expected) {
+		t.Errorf("expected zones %v, got %v", expected, zones)
+	}
+}
+
+func TestWorkerNodeFilter(t *testing.T) {
+	master := newNode("master", "us-east-1c")
+	master.Labels = map[string]string{masterNodeLabel: ""}
+	schedulableMaster := newNode("schedulable-master", "us-east-1b")
+	schedulableMaster.Labels = map[string]string{masterNodeLabel: "", workerNodeLabel: ""}
+	worker := newNode("worker", "us-east-1a")
+	worker.Labels = map[string]string{workerNodeLabel: ""}
+
+	tests := []struct {
+		name     string
+		obj      interface{}
+		expected bool
+	}{
+		{name: "worker", obj: worker, expected: true},
+		{name: "node without a role", obj: newNode("a", "us-east-1a"), expected: true},
+		{name: "schedulable master", obj: schedulableMaster, expected: true},
+		{name: "master", obj: master, expected: false},
+		{name: "deleted worker", obj: cache.DeletedFinalStateUnknown{Key: "worker", Obj: worker}, expected: true},
+		{name: "deleted master", obj: cache.DeletedFinalStateUnknown{Key: "master", Obj: master}, expected: false},
+		{name: "not a node", obj: newStorageClass("efs", "fs-1"), expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := workerNodeFilter(test.obj); got != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestReportUncoveredNodes(t *testing.T) {
+	client := &fakeEFS{
+		fileSystems:  map[string]string{"fs-1": awsefs.LifeCycleStateAvailable},
+		mountTargets: map[string][]string{"fs-1": {"us-east-1a"}},
+	}
+	sc := newStorageClass("efs", "fs-1")
+	c, _ := newTestController(client, sc, newNode("a", "us-east-1a"), newNode("b", "us-east-1b"))
+	recorder := events.NewInMemoryRecorder("test")
+	syncCtx := factory.NewSyncContext("test", recorder)
+
+	steps := []struct {
+		name string
+		// changes the state before the sync
+		change         func()
+		expectedEvents int
+	}{
+		{
+			name:           "zone uncovered",
+			expectedEvents: 1,
+		},
+		{
+			name:           "no change",
+			expectedEvents: 1,
+		},
+		{
+			name: "new node in the uncovered zone",
+			change: func() {
+				c.nodeLister = corev1listers.NewNodeLister(newIndexer(newNode("a", "us-east-1a"), newNode("b", "us-east-1b"), newNode("c", "us-east-1b")))
+			},
+			expectedEvents: 2,
+		},
+		{
+			name: "zone covered",
+			change: func() {
+				client.mountTargets["fs-1"] = []string{"us-east-1a", "us-east-1b"}
+			},
+			expectedEvents: 2,
+		},
+		{
+			name: "zone uncovered again",
+			change: func() {
+				client.mountTargets["fs-1"] = []string{"us-east-1a"}
+			},
+			expectedEvents: 3,
+		},
+	}
+	for _, step := range steps {
+		if step.change != nil {
+			step.change()
+		}
+		if err := c.sync(context.TODO(), syncCtx); err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+		var warnings int
+		for _, event := range recorder.Events() {
+			if event.Reason == "NodesWithoutMountTarget" {
+				warnings++
+			}
+		}
+		if warnings != step.expectedEvents {
+			t.Errorf("%s: expected %d NodesWithoutMountTarget events, got %d", step.name, step.expectedEvents, warnings)
+		}
+	}
+}
+
+func newIndexer(objects ...runtime.Object) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, obj := range objects {
+		indexer.Add(obj)
+	}
+	return indexer
+}
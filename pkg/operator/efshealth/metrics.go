@@ -0,0 +1,29 @@
+package efshealth
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var nodesWithoutMountTarget = metrics.NewGaugeVec(
+	&metrics.GaugeOpts{
+		Name:           "aws_efs_csi_driver_operator_nodes_without_mount_target",
+		Help:           "Number of nodes in a zone where an EFS file system referenced by a StorageClass has no available mount target.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"file_system_id", "zone"},
+)
+
+func init() {
+	legacyregistry.MustRegister(nodesWithoutMountTarget)
+}
+
+// updateMetrics replaces the reported uncovered zones with the current ones.
+func updateMetrics(statuses []*fileSystemStatus) {
+	nodesWithoutMountTarget.Reset()
+	for _, status := range statuses {
+		for zone, nodes := range status.uncoveredZones {
+			nodesWithoutMountTarget.WithLabelValues(status.id, zone).Set(float64(len(nodes)))
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/management"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+)
+
+// monitoringController applies the ServiceMonitor and the PrometheusRule of
+// the operator metrics and removes them when the operator is removed.
+// library-go's StaticResourceController does not remove objects, so this is a
+// variant of it with a finalizer. A missing monitoring stack is not an error,
+// the objects are applied once their CRDs exist.
+type monitoringController struct {
+	name           string
+	operatorClient v1helpers.OperatorClientWithFinalizers
+	clients        *resourceapply.ClientHolder
+	cache          resourceapply.ResourceCache
+	assetFunc      resourceapply.AssetFunc
+	files          []string
+	eventRecorder  events.Recorder
+}
+
+func newMonitoringController(
+	name string,
+	operatorClient v1helpers.OperatorClientWithFinalizers,
+	dynamicClient dynamic.Interface,
+	assetFunc resourceapply.AssetFunc,
+	files []string,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &monitoringController{
+		name:           name,
+		operatorClient: operatorClient,
+		clients:        (&resourceapply.ClientHolder{}).WithDynamicClient(dynamicClient),
+		cache:          resourceapply.NewResourceCache(),
+		assetFunc:      assetFunc,
+		files:          files,
+		eventRecorder:  recorder,
+	}
+	return factory.New().
+		WithSyncDegradedOnError(operatorClient).
+		WithInformers(operatorClient.Informer()).
+		WithSync(c.sync).
+		ResyncEvery(time.Minute).
+		ToController(name, recorder.WithComponentSuffix("monitoring-controller"))
+}
+
+func (c *monitoringController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	meta, err := c.operatorClient.GetObjectMeta()
+	if err != nil {
+		return err
+	}
+	if management.IsOperatorRemovable() && meta.DeletionTimestamp != nil {
+		results := resourceapply.DeleteAll(ctx, c.clients, c.eventRecorder, c.assetFunc, c.files...)
+		if err := resultsError(results); err != nil {
+			return err
+		}
+		return v1helpers.RemoveFinalizer(ctx, c.operatorClient, c.name)
+	}
+	if err := v1helpers.EnsureFinalizer(ctx, c.operatorClient, c.name); err != nil {
+		return err
+	}
+
+	results := resourceapply.ApplyDirectly(ctx, c.clients, c.eventRecorder, c.cache, c.assetFunc, c.files...)
+	return resultsError(results)
+}
+
+// resultsError aggregates errors of the results. NotFound errors are ignored,
+// they're returned when the monitoring CRDs do not exist.
+func resultsError(results []resourceapply.ApplyResult) error {
+	var errs []error
+	for _, result := range results {
+		if errors.IsNotFound(result.Error) {
+			klog.V(4).Infof("Skipping %s: %v", result.File, result.Error)
+			continue
+		}
+		if result.Error != nil {
+			errs = append(errs, fmt.Errorf("%q (%T): %v", result.File, result.Type, result.Error))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,106 @@
+package operator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+const testMonitoringControllerName = "TestMonitoringController"
+
+var (
+	serviceMonitorGVR = schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "servicemonitors"}
+	prometheusRuleGVR = schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "prometheusrules"}
+)
+
+func TestMonitoringController(t *testing.T) {
+	deleting := metav1.Now()
+	finalizer := testOperatorName + ".operator.openshift.io/" + testMonitoringControllerName
+	tests := []struct {
+		name string
+		meta *metav1.ObjectMeta
+		// expected results
+		expectedObjects    bool
+		expectedFinalizers []string
+	}{
+		{
+			name:               "managed",
+			meta:               &metav1.ObjectMeta{Name: "cluster"},
+			expectedObjects:    true,
+			expectedFinalizers: []string{finalizer},
+		},
+		{
+			name: "operator removed",
+			meta: &metav1.ObjectMeta{
+				Name:              "cluster",
+				DeletionTimestamp: &deleting,
+				Finalizers:        []string{finalizer, "other"},
+			},
+			expectedFinalizers: []string{"other"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("OPERATOR_NAME", testOperatorName)
+			scheme := runtime.NewScheme()
+			dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, map[schema.GroupVersionResource]string{
+				serviceMonitorGVR: "ServiceMonitorList",
+				prometheusRuleGVR: "PrometheusRuleList",
+			})
+			operatorClient := v1helpers.NewFakeOperatorClientWithObjectMeta(test.meta, &opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil)
+			c := &monitoringController{
+				name:           testMonitoringControllerName,
+				operatorClient: operatorClient,
+				clients:        (&resourceapply.ClientHolder{}).WithDynamicClient(dynamicClient),
+				cache:          resourceapply.NewResourceCache(),
+				assetFunc:      replaceNamespaceFunc(testNamespace),
+				files:          []string{"operator_servicemonitor.yaml", "prometheusrule.yaml"},
+				eventRecorder:  events.NewInMemoryRecorder("test"),
+			}
+			// Objects of the previous sync exist when the operator is removed
+			if test.meta.DeletionTimestamp != nil {
+				resourceapply.ApplyDirectly(context.TODO(), c.clients, c.eventRecorder, c.cache, c.assetFunc, c.files...)
+			}
+
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, gvr := range []schema.GroupVersionResource{serviceMonitorGVR, prometheusRuleGVR} {
+				list, err := dynamicClient.Resource(gvr).Namespace(testNamespace).List(context.TODO(), metav1.ListOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if exists := len(list.Items) > 0; exists != test.expectedObjects {
+					t.Errorf("expected %s to exist: %v, got %v", gvr.Resource, test.expectedObjects, names(list.Items))
+				}
+			}
+			meta, err := operatorClient.GetObjectMeta()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(meta.Finalizers, test.expectedFinalizers) {
+				t.Errorf("expected finalizers %v, got %v", test.expectedFinalizers, meta.Finalizers)
+			}
+		})
+	}
+}
+
+func names(items []unstructured.Unstructured) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.GetName())
+	}
+	return names
+}
@@ -0,0 +1,122 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	configv1informers "github.com/openshift/client-go/config/informers/externalversions/config/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	infrastructureName = "cluster"
+	tagsFlag           = "--tags"
+)
+
+// withDriverTagsHook sets the --tags arg of the driver, so the tags are set on
+// EFS access points. Tags are merged by key with this precedence:
+//  1. tags from controller.yaml, i.e. the cluster ownership tag
+//  2. extraTags from the driver ConfigMap
+//  3. user-defined AWS resource tags from the Infrastructure
+//
+// A change of the tags rolls out the controller pods. An invalid ConfigMap is
+// reported as an error, which makes the controller Degraded and keeps the
+// current Deployment untouched.
+func withDriverTagsHook(
+	namespace, configMapName string,
+	configMapInformer corev1informers.ConfigMapInformer,
+	infraInformer configv1informers.InfrastructureInformer) dc.DeploymentHookFunc {
+
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		infra, err := infraInformer.Lister().Get(infrastructureName)
+		if err != nil {
+			return fmt.Errorf("error getting infrastructure %s: %v", infrastructureName, err)
+		}
+		var extraTags []string
+		cm, err := configMapInformer.Lister().ConfigMaps(namespace).Get(configMapName)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			config, err := parseDriverConfig(cm)
+			if err != nil {
+				return fmt.Errorf("invalid ConfigMap %s/%s: %v", namespace, configMapName, err)
+			}
+			extraTags = config.extraTags
+		}
+
+		containers := deployment.Spec.Template.Spec.Containers
+		for i := range containers {
+			if containers[i].Name != driverContainerName {
+				continue
+			}
+			tags := mergeTags(getContainerTags(containers[i].Args), extraTags, getDriverTags(infra))
+			if len(tags) > 0 {
+				setContainerArg(&containers[i], tagsFlag, strings.Join(tags, " "))
+			}
+			return nil
+		}
+		return fmt.Errorf("container %s not found in deployment %s", driverContainerName, deployment.Name)
+	}
+}
+
+// getDriverTags returns resource tags of the Infrastructure in <key>:<value>
+// format of the --tags arg. The arg is space separated and split on the first
+// colon, tags that can't be expressed that way are skipped.
+func getDriverTags(infra *configv1.Infrastructure) []string {
+	if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.AWS == nil {
+		return nil
+	}
+	var tags []string
+	for _, tag := range infra.Status.PlatformStatus.AWS.ResourceTags {
+		if strings.ContainsAny(tag.Key, ": \t") || strings.ContainsAny(tag.Value, " \t") {
+			klog.Warningf("Skipping resource tag %q: %q, it can't be passed to the driver", tag.Key, tag.Value)
+			continue
+		}
+		tags = append(tags, tag.Key+":"+tag.Value)
+	}
+	return tags
+}
+
+// getContainerTags returns tags of an existing --tags arg.
+func getContainerTags(args []string) []string {
+	for _, arg := range args {
+		if value, found := strings.CutPrefix(arg, tagsFlag+"="); found {
+			return strings.Fields(value)
+		}
+	}
+	return nil
+}
+
+// mergeTags merges <key>:<value> tags by key. A key is taken from the first
+// list that has it, so earlier lists take precedence. The result keeps the
+// order in which the keys were first seen.
+func mergeTags(tagLists ...[]string) []string {
+	var merged []string
+	seen := map[string]string{}
+	for _, tags := range tagLists {
+		for _, tag := range tags {
+			key := tagKey(tag)
+			if existing, found := seen[key]; found {
+				if existing != tag {
+					klog.V(4).Infof("Ignoring tag %q, %q takes precedence", tag, existing)
+				}
+				continue
+			}
+			seen[key] = tag
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+func tagKey(tag string) string {
+	key, _, _ := strings.Cut(tag, ":")
+	return key
+}
@@ -0,0 +1,131 @@
+package operator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	configfake "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMergeTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		tagLists [][]string
+		expected []string
+	}{
+		{
+			name: "no tags",
+		},
+		{
+			name:     "disjoint keys keep order",
+			tagLists: [][]string{{"a:1"}, {"c:3", "b:2"}},
+			expected: []string{"a:1", "c:3", "b:2"},
+		},
+		{
+			name:     "earlier list wins",
+			tagLists: [][]string{{"a:1"}, {"a:2", "b:2"}, {"b:3", "c:3"}},
+			expected: []string{"a:1", "b:2", "c:3"},
+		},
+		{
+			name:     "value with colon",
+			tagLists: [][]string{{"url:https://example.com"}, {"url:other"}},
+			expected: []string{"url:https://example.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if merged := mergeTags(test.tagLists...); !reflect.DeepEqual(merged, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, merged)
+			}
+		})
+	}
+}
+
+func TestWithDriverTagsHook(t *testing.T) {
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+		Status: configv1.InfrastructureStatus{
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.AWSPlatformType,
+				AWS: &configv1.AWSPlatformStatus{
+					ResourceTags: []configv1.AWSResourceTag{
+						{Key: "team", Value: "platform"},
+						{Key: "cost-center", Value: "42"},
+						{Key: "bad key", Value: "skipped"},
+					},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name         string
+		configMap    *corev1.ConfigMap
+		expectedTags string
+		expectedErr  string
+	}{
+		{
+			name:         "infrastructure tags",
+			expectedTags: "kubernetes.io/cluster/test:owned team:platform cost-center:42",
+		},
+		{
+			name:         "extra tags override infrastructure tags",
+			configMap:    newDriverConfigMap(map[string]string{extraTagsKey: "env:prod team:storage"}),
+			expectedTags: "kubernetes.io/cluster/test:owned env:prod team:storage cost-center:42",
+		},
+		{
+			name:        "invalid extra tags",
+			configMap:   newDriverConfigMap(map[string]string{extraTagsKey: "env:prod env:dev"}),
+			expectedErr: `duplicate tag "env"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configMapInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().ConfigMaps()
+			if test.configMap != nil {
+				configMapInformer.Informer().GetIndexer().Add(test.configMap)
+			}
+			infraInformer := configinformers.NewSharedInformerFactory(configfake.NewSimpleClientset(), 0).Config().V1().Infrastructures()
+			infraInformer.Informer().GetIndexer().Add(infra)
+
+			deployment := &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name: driverContainerName,
+								Args: []string{"--tags=kubernetes.io/cluster/test:owned", "--v=2"},
+							}},
+						},
+					},
+				},
+			}
+			hook := withDriverTagsHook(testNamespace, driverConfigMapName, configMapInformer, infraInformer)
+			// The hook runs on every sync, applying it twice must not duplicate tags
+			for i := 0; i < 2; i++ {
+				err := hook(&opv1.OperatorSpec{}, deployment)
+				if test.expectedErr != "" {
+					if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+						t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			expectedArgs := []string{"--tags=" + test.expectedTags, "--v=2"}
+			if args := deployment.Spec.Template.Spec.Containers[0].Args; !reflect.DeepEqual(args, expectedArgs) {
+				t.Errorf("expected args %v, got %v", expectedArgs, args)
+			}
+		})
+	}
+}
@@ -10,12 +10,13 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"github.com/openshift/aws-efs-csi-driver-operator/assets"
+	"github.com/openshift/aws-efs-csi-driver-operator/pkg/operator/efshealth"
 	"github.com/openshift/aws-efs-csi-driver-operator/pkg/operator/staticresource"
+	"github.com/openshift/aws-efs-csi-driver-operator/pkg/version"
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/csi/csidrivercontrollerservicecontroller"
 	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
-	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
 	"k8s.io/client-go/dynamic"
 	kubeclient "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -107,6 +108,7 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 			secretInformer.Informer(),
 			nodeInformer.Informer(),
 			infraInformer.Informer(),
+			configMapInformer.Informer(),
 		},
 		csidrivercontrollerservicecontroller.WithCABundleDeploymentHook(
 			operatorNamespace,
@@ -115,6 +117,10 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		),
 		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(operatorNamespace, cloudCredSecretName, secretInformer),
 		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(operatorNamespace, metricsCertSecretName, secretInformer),
+		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(operatorNamespace, crossAccountSecretName, secretInformer),
+		withCrossAccountRoleHook(operatorNamespace, crossAccountSecretName, secretInformer),
+		withDriverTagsHook(operatorNamespace, driverConfigMapName, configMapInformer, infraInformer),
+		withDriverConfigHook(operatorNamespace, driverConfigMapName, configMapInformer),
 		csidrivercontrollerservicecontroller.WithObservedProxyDeploymentHook(),
 		csidrivercontrollerservicecontroller.WithReplicasHook(nodeInformer.Lister()),
 	).WithCredentialsRequestController(
@@ -132,23 +138,6 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		"servicemonitor.yaml",
 	)
 
-	objsToSync := staticresource.SyncObjects{
-		CSIDriver:                      resourceread.ReadCSIDriverV1OrDie(mustReplaceNamespace(operatorNamespace, "csidriver.yaml")),
-		PrivilegedRole:                 resourceread.ReadClusterRoleV1OrDie(mustReplaceNamespace(operatorNamespace, "rbac/privileged_role.yaml")),
-		NodeServiceAccount:             resourceread.ReadServiceAccountV1OrDie(mustReplaceNamespace(operatorNamespace, "node_sa.yaml")),
-		NodeRoleBinding:                resourceread.ReadClusterRoleBindingV1OrDie(mustReplaceNamespace(operatorNamespace, "rbac/node_privileged_binding.yaml")),
-		ControllerServiceAccount:       resourceread.ReadServiceAccountV1OrDie(mustReplaceNamespace(operatorNamespace, "controller_sa.yaml")),
-		ControllerRoleBinding:          resourceread.ReadClusterRoleBindingV1OrDie(mustReplaceNamespace(operatorNamespace, "rbac/controller_privileged_binding.yaml")),
-		ProvisionerRoleBinding:         resourceread.ReadClusterRoleBindingV1OrDie(mustReplaceNamespace(operatorNamespace, "rbac/main_provisioner_binding.yaml")),
-		PrometheusRole:                 resourceread.ReadRoleV1OrDie(mustReplaceNamespace(operatorNamespace, "rbac/prometheus_role.yaml")),
-		PrometheusRoleBinding:          resourceread.ReadRoleBindingV1OrDie(mustReplaceNamespace(operatorNamespace, "rbac/prometheus_rolebinding.yaml")),
-		LeaseLeaderElectionRole:        resourceread.ReadRoleV1OrDie(mustReplaceNamespace(operatorNamespace, "rbac/lease_leader_election_role.yaml")),
-		LeaseLeaderElectionRoleBinding: resourceread.ReadRoleBindingV1OrDie(mustReplaceNamespace(operatorNamespace, "rbac/lease_leader_election_rolebinding.yaml")),
-		MetricsService:                 resourceread.ReadServiceV1OrDie(mustReplaceNamespace(operatorNamespace, "service.yaml")),
-		RBACProxyRole:                  resourceread.ReadClusterRoleV1OrDie(mustReplaceNamespace(operatorNamespace, "rbac/kube_rbac_proxy_role.yaml")),
-		RBACProxyRoleBinding:           resourceread.ReadClusterRoleBindingV1OrDie(mustReplaceNamespace(operatorNamespace, "rbac/kube_rbac_proxy_binding.yaml")),
-		CAConfigMap:                    resourceread.ReadConfigMapV1OrDie(mustReplaceNamespace(operatorNamespace, "cabundle_cm.yaml")),
-	}
 	staticController := staticresource.NewCSIStaticResourceController(
 		"CSIStaticResourceController",
 		operatorNamespace,
@@ -156,7 +145,69 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		kubeClient,
 		kubeInformersForNamespaces,
 		controllerConfig.EventRecorder,
-		objsToSync,
+		version.Get().GitVersion,
+		replaceNamespaceFunc(operatorNamespace),
+		[]string{
+			"csidriver.yaml",
+			"rbac/privileged_role.yaml",
+			"cabundle_cm.yaml",
+			"node_sa.yaml",
+			"rbac/node_privileged_binding.yaml",
+			"controller_sa.yaml",
+			"rbac/controller_privileged_binding.yaml",
+			"rbac/main_provisioner_binding.yaml",
+			"rbac/lease_leader_election_role.yaml",
+			"rbac/lease_leader_election_rolebinding.yaml",
+			"rbac/prometheus_role.yaml",
+			"rbac/prometheus_rolebinding.yaml",
+			"service.yaml",
+			"operator_service.yaml",
+			"rbac/kube_rbac_proxy_role.yaml",
+			"rbac/kube_rbac_proxy_binding.yaml",
+			"rbac/cross_account_secret_role.yaml",
+			"rbac/cross_account_secret_binding.yaml",
+		},
+		// Applied by storageClassController only when a file system is configured
+		[]string{storageClassAsset},
+	)
+
+	storageClassController := newStorageClassController(
+		"AWSEFSDriverStorageClassController",
+		operatorNamespace,
+		driverConfigMapName,
+		crossAccountSecretName,
+		operatorClient,
+		kubeClient,
+		configMapInformer,
+		secretInformer,
+		kubeInformersForNamespaces.InformersFor("").Storage().V1().StorageClasses(),
+		operatorInformer,
+		controllerConfig.EventRecorder,
+	)
+
+	// Metrics of the operator and alerts based on them
+	monitoringController := newMonitoringController(
+		"AWSEFSDriverOperatorMonitoringController",
+		operatorClient,
+		dynamicClient,
+		replaceNamespaceFunc(operatorNamespace),
+		[]string{
+			"operator_servicemonitor.yaml",
+			"prometheusrule.yaml",
+		},
+		controllerConfig.EventRecorder,
+	)
+
+	efsHealthController := efshealth.NewEFSHealthController(
+		"AWSEFSDriverHealthController",
+		operatorNamespace,
+		cloudCredSecretName,
+		operatorClient,
+		secretInformer,
+		kubeInformersForNamespaces.InformersFor("").Storage().V1().StorageClasses(),
+		nodeInformer,
+		infraInformer,
+		controllerConfig.EventRecorder,
 	)
 
 	klog.Info("Starting the informers")
@@ -168,20 +219,15 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 	klog.Info("Starting controllerset")
 	go cs.Run(ctx, 1)
 	go staticController.Run(ctx, 1)
+	go storageClassController.Run(ctx, 1)
+	go efsHealthController.Run(ctx, 1)
+	go monitoringController.Run(ctx, 1)
 
 	<-ctx.Done()
 
 	return nil
 }
 
-func mustReplaceNamespace(namespace, file string) []byte {
-	content, err := assets.ReadFile(file)
-	if err != nil {
-		panic(err)
-	}
-	return bytes.Replace(content, []byte(namespaceReplaceKey), []byte(namespace), -1)
-}
-
 func replaceNamespaceFunc(namespace string) resourceapply.AssetFunc {
 	return func(name string) ([]byte, error) {
 		content, err := assets.ReadFile(name)
@@ -0,0 +1,216 @@
+package staticresource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourcehelper"
+	"github.com/openshift/library-go/pkg/operator/resource/resourcemerge"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/errors"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	networkingclientv1 "k8s.io/client-go/kubernetes/typed/networking/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	rbaclisters "k8s.io/client-go/listers/rbac/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+)
+
+// applyObject applies the object with the resourceapply function of its kind.
+func applyObject(ctx context.Context, client kubernetes.Interface, recorder events.Recorder, obj runtime.Object) (runtime.Object, bool, error) {
+	switch t := obj.(type) {
+	case *storagev1.CSIDriver:
+		return resourceapply.ApplyCSIDriver(ctx, client.StorageV1(), recorder, t)
+	case *storagev1.StorageClass:
+		return resourceapply.ApplyStorageClass(ctx, client.StorageV1(), recorder, t)
+	case *rbacv1.ClusterRole:
+		return resourceapply.ApplyClusterRole(ctx, client.RbacV1(), recorder, t)
+	case *rbacv1.ClusterRoleBinding:
+		return resourceapply.ApplyClusterRoleBinding(ctx, client.RbacV1(), recorder, t)
+	case *rbacv1.Role:
+		return resourceapply.ApplyRole(ctx, client.RbacV1(), recorder, t)
+	case *rbacv1.RoleBinding:
+		return resourceapply.ApplyRoleBinding(ctx, client.RbacV1(), recorder, t)
+	case *corev1.ServiceAccount:
+		return resourceapply.ApplyServiceAccount(ctx, client.CoreV1(), recorder, t)
+	case *corev1.ConfigMap:
+		return resourceapply.ApplyConfigMap(ctx, client.CoreV1(), recorder, t)
+	case *corev1.Service:
+		return resourceapply.ApplyService(ctx, client.CoreV1(), recorder, t)
+	case *networkingv1.NetworkPolicy:
+		return applyNetworkPolicy(ctx, client.NetworkingV1(), recorder, t)
+	}
+	return nil, false, fmt.Errorf("unhandled type %T", obj)
+}
+
+// deleteObject deletes the object with the client of its kind. It returns
+// NotFound error when the object does not exist.
+func deleteObject(ctx context.Context, client kubernetes.Interface, obj runtime.Object) error {
+	opts := metav1.DeleteOptions{}
+	switch t := obj.(type) {
+	case *storagev1.CSIDriver:
+		return client.StorageV1().CSIDrivers().Delete(ctx, t.Name, opts)
+	case *storagev1.StorageClass:
+		return client.StorageV1().StorageClasses().Delete(ctx, t.Name, opts)
+	case *rbacv1.ClusterRole:
+		return client.RbacV1().ClusterRoles().Delete(ctx, t.Name, opts)
+	case *rbacv1.ClusterRoleBinding:
+		return client.RbacV1().ClusterRoleBindings().Delete(ctx, t.Name, opts)
+	case *rbacv1.Role:
+		return client.RbacV1().Roles(t.Namespace).Delete(ctx, t.Name, opts)
+	case *rbacv1.RoleBinding:
+		return client.RbacV1().RoleBindings(t.Namespace).Delete(ctx, t.Name, opts)
+	case *corev1.ServiceAccount:
+		return client.CoreV1().ServiceAccounts(t.Namespace).Delete(ctx, t.Name, opts)
+	case *corev1.ConfigMap:
+		return client.CoreV1().ConfigMaps(t.Namespace).Delete(ctx, t.Name, opts)
+	case *corev1.Service:
+		return client.CoreV1().Services(t.Namespace).Delete(ctx, t.Name, opts)
+	case *networkingv1.NetworkPolicy:
+		return client.NetworkingV1().NetworkPolicies(t.Namespace).Delete(ctx, t.Name, opts)
+	}
+	return fmt.Errorf("unhandled type %T", obj)
+}
+
+// objectListers lists objects of all kinds handled by applyObject from
+// informer caches.
+type objectListers struct {
+	csiDrivers          storagelisters.CSIDriverLister
+	storageClasses      storagelisters.StorageClassLister
+	clusterRoles        rbaclisters.ClusterRoleLister
+	clusterRoleBindings rbaclisters.ClusterRoleBindingLister
+	roles               rbaclisters.RoleLister
+	roleBindings        rbaclisters.RoleBindingLister
+	serviceAccounts     corelisters.ServiceAccountLister
+	configMaps          corelisters.ConfigMapLister
+	services            corelisters.ServiceLister
+	networkPolicies     networkinglisters.NetworkPolicyLister
+}
+
+func newObjectListers(informers kubeinformers.SharedInformerFactory) *objectListers {
+	return &objectListers{
+		csiDrivers:          informers.Storage().V1().CSIDrivers().Lister(),
+		storageClasses:      informers.Storage().V1().StorageClasses().Lister(),
+		clusterRoles:        informers.Rbac().V1().ClusterRoles().Lister(),
+		clusterRoleBindings: informers.Rbac().V1().ClusterRoleBindings().Lister(),
+		roles:               informers.Rbac().V1().Roles().Lister(),
+		roleBindings:        informers.Rbac().V1().RoleBindings().Lister(),
+		serviceAccounts:     informers.Core().V1().ServiceAccounts().Lister(),
+		configMaps:          informers.Core().V1().ConfigMaps().Lister(),
+		services:            informers.Core().V1().Services().Lister(),
+		networkPolicies:     informers.Networking().V1().NetworkPolicies().Lister(),
+	}
+}
+
+// get returns the cached object with the kind, namespace and name of the
+// object. The object must not be modified.
+func (l *objectListers) get(obj runtime.Object) (runtime.Object, error) {
+	switch t := obj.(type) {
+	case *storagev1.CSIDriver:
+		return l.csiDrivers.Get(t.Name)
+	case *storagev1.StorageClass:
+		return l.storageClasses.Get(t.Name)
+	case *rbacv1.ClusterRole:
+		return l.clusterRoles.Get(t.Name)
+	case *rbacv1.ClusterRoleBinding:
+		return l.clusterRoleBindings.Get(t.Name)
+	case *rbacv1.Role:
+		return l.roles.Roles(t.Namespace).Get(t.Name)
+	case *rbacv1.RoleBinding:
+		return l.roleBindings.RoleBindings(t.Namespace).Get(t.Name)
+	case *corev1.ServiceAccount:
+		return l.serviceAccounts.ServiceAccounts(t.Namespace).Get(t.Name)
+	case *corev1.ConfigMap:
+		return l.configMaps.ConfigMaps(t.Namespace).Get(t.Name)
+	case *corev1.Service:
+		return l.services.Services(t.Namespace).Get(t.Name)
+	case *networkingv1.NetworkPolicy:
+		return l.networkPolicies.NetworkPolicies(t.Namespace).Get(t.Name)
+	}
+	return nil, fmt.Errorf("unhandled type %T", obj)
+}
+
+// list returns objects of all kinds that match the selector. Namespaced kinds
+// are listed only in the namespace. The objects are from the informer caches
+// and must not be modified.
+func (l *objectListers) list(namespace string, selector labels.Selector) ([]runtime.Object, error) {
+	var objs []runtime.Object
+	var errs []error
+	csiDrivers, err := l.csiDrivers.List(selector)
+	objs, errs = appendObjects(objs, errs, csiDrivers, err)
+	storageClasses, err := l.storageClasses.List(selector)
+	objs, errs = appendObjects(objs, errs, storageClasses, err)
+	clusterRoles, err := l.clusterRoles.List(selector)
+	objs, errs = appendObjects(objs, errs, clusterRoles, err)
+	clusterRoleBindings, err := l.clusterRoleBindings.List(selector)
+	objs, errs = appendObjects(objs, errs, clusterRoleBindings, err)
+	roles, err := l.roles.Roles(namespace).List(selector)
+	objs, errs = appendObjects(objs, errs, roles, err)
+	roleBindings, err := l.roleBindings.RoleBindings(namespace).List(selector)
+	objs, errs = appendObjects(objs, errs, roleBindings, err)
+	serviceAccounts, err := l.serviceAccounts.ServiceAccounts(namespace).List(selector)
+	objs, errs = appendObjects(objs, errs, serviceAccounts, err)
+	configMaps, err := l.configMaps.ConfigMaps(namespace).List(selector)
+	objs, errs = appendObjects(objs, errs, configMaps, err)
+	services, err := l.services.Services(namespace).List(selector)
+	objs, errs = appendObjects(objs, errs, services, err)
+	networkPolicies, err := l.networkPolicies.NetworkPolicies(namespace).List(selector)
+	objs, errs = appendObjects(objs, errs, networkPolicies, err)
+	return objs, errors.NewAggregate(errs)
+}
+
+// appendObjects appends the items of a lister result to objs, or its error to errs.
+func appendObjects[T runtime.Object](objs []runtime.Object, errs []error, items []T, err error) ([]runtime.Object, []error) {
+	if err != nil {
+		return objs, append(errs, err)
+	}
+	for _, item := range items {
+		objs = append(objs, item)
+	}
+	return objs, errs
+}
+
+// applyNetworkPolicy merges objectmeta and requires spec. library-go does not
+// have an apply function for NetworkPolicies.
+func applyNetworkPolicy(ctx context.Context, client networkingclientv1.NetworkPoliciesGetter, recorder events.Recorder, required *networkingv1.NetworkPolicy) (*networkingv1.NetworkPolicy, bool, error) {
+	existing, err := client.NetworkPolicies(required.Namespace).Get(ctx, required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		actual, err := client.NetworkPolicies(required.Namespace).Create(ctx, required, metav1.CreateOptions{})
+		if err != nil {
+			recorder.Warningf("NetworkPolicyCreateFailed", "Failed to create %s: %v", resourcehelper.FormatResourceForCLIWithNamespace(required), err)
+		} else {
+			recorder.Eventf("NetworkPolicyCreated", "Created %s because it was missing", resourcehelper.FormatResourceForCLIWithNamespace(required))
+		}
+		return actual, true, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	modified := resourcemerge.BoolPtr(false)
+	existingCopy := existing.DeepCopy()
+	resourcemerge.EnsureObjectMeta(modified, &existingCopy.ObjectMeta, required.ObjectMeta)
+	if !*modified && equality.Semantic.DeepEqual(existingCopy.Spec, required.Spec) {
+		return existingCopy, false, nil
+	}
+
+	existingCopy.Spec = required.Spec
+	actual, err := client.NetworkPolicies(required.Namespace).Update(ctx, existingCopy, metav1.UpdateOptions{})
+	if err != nil {
+		recorder.Warningf("NetworkPolicyUpdateFailed", "Failed to update %s: %v", resourcehelper.FormatResourceForCLIWithNamespace(required), err)
+	} else {
+		recorder.Eventf("NetworkPolicyUpdated", "Updated %s because it changed", resourcehelper.FormatResourceForCLIWithNamespace(required))
+	}
+	return actual, true, err
+}
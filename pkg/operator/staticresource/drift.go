@@ -0,0 +1,180 @@
+package staticresource
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/resource/resourcehelper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/clock"
+)
+
+const (
+	driftingCondition = "StaticResourcesDrifting"
+	// An object reverted driftThreshold times within driftWindow is drifting,
+	// i.e. someone keeps changing it.
+	driftThreshold = 3
+	driftWindow    = time.Hour
+)
+
+// driftTracker remembers when objects were reverted to their assets. It is
+// kept only in memory, the reverts are forgotten when the operator restarts
+// or loses the leader election.
+type driftTracker struct {
+	clock   clock.PassiveClock
+	reverts map[string][]time.Time
+}
+
+func newDriftTracker(clock clock.PassiveClock) *driftTracker {
+	return &driftTracker{
+		clock:   clock,
+		reverts: map[string][]time.Time{},
+	}
+}
+
+// recordRevert records a revert of the object with the key.
+func (t *driftTracker) recordRevert(key string) {
+	t.reverts[key] = append(t.reverts[key], t.clock.Now())
+}
+
+// drifting returns sorted keys of objects reverted at least driftThreshold
+// times within driftWindow. Older reverts are forgotten.
+func (t *driftTracker) drifting() []string {
+	since := t.clock.Now().Add(-driftWindow)
+	var keys []string
+	for key, reverts := range t.reverts {
+		var recent []time.Time
+		for _, revert := range reverts {
+			if revert.After(since) {
+				recent = append(recent, revert)
+			}
+		}
+		if len(recent) == 0 {
+			delete(t.reverts, key)
+			continue
+		}
+		t.reverts[key] = recent
+		if len(recent) >= driftThreshold {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reportRevert reports that the live object was changed by someone else and
+// reverted by apply.
+func (c *CSIStaticResourceController) reportRevert(live, applied runtime.Object) error {
+	fields, err := changedFields(live, applied)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		// Only metadata managed by the controller changed, e.g. the version label after an upgrade
+		return nil
+	}
+
+	name := resourcehelper.FormatResourceForCLIWithNamespace(applied)
+	c.eventRecorder.Warningf("StaticResourceReverted", "Reverted changed fields of %s: %s", name, strings.Join(fields, ", "))
+	accessor := applied.(metav1.Object)
+	gvk := resourcehelper.GuessObjectGroupVersionKind(applied)
+	staticResourceReverts.WithLabelValues(gvk.Kind, accessor.GetNamespace(), accessor.GetName()).Inc()
+	c.driftTracker.recordRevert(name)
+	return nil
+}
+
+// driftingCondition returns the StaticResourcesDrifting condition of the objects
+// reverted repeatedly.
+func (c *CSIStaticResourceController) driftingCondition() opv1.OperatorCondition {
+	drifting := c.driftTracker.drifting()
+	if len(drifting) == 0 {
+		return opv1.OperatorCondition{
+			Type:   driftingCondition,
+			Status: opv1.ConditionFalse,
+			Reason: "AsExpected",
+		}
+	}
+	return opv1.OperatorCondition{
+		Type:    driftingCondition,
+		Status:  opv1.ConditionTrue,
+		Reason:  "ObjectsRepeatedlyReverted",
+		Message: fmt.Sprintf("Objects changed by someone else and reverted %d or more times in %s: %s", driftThreshold, driftWindow, strings.Join(drifting, ", ")),
+	}
+}
+
+// changedFields returns sorted paths of fields that differ between the live
+// object and the applied one. Metadata other than labels and annotations is
+// ignored, and so are the labels set by the controller.
+func changedFields(live, applied runtime.Object) ([]string, error) {
+	liveMap, err := comparableFields(live)
+	if err != nil {
+		return nil, err
+	}
+	appliedMap, err := comparableFields(applied)
+	if err != nil {
+		return nil, err
+	}
+	fields := diffPaths("", liveMap, appliedMap)
+	sort.Strings(fields)
+	return fields, nil
+}
+
+func comparableFields(obj runtime.Object) (map[string]interface{}, error) {
+	fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "apiVersion")
+	delete(fields, "kind")
+	delete(fields, "status")
+	metadata := map[string]interface{}{}
+	if objMeta, ok := fields["metadata"].(map[string]interface{}); ok {
+		if labels, ok := objMeta["labels"].(map[string]interface{}); ok {
+			delete(labels, ownerLabel)
+			delete(labels, versionLabel)
+			if len(labels) > 0 {
+				metadata["labels"] = labels
+			}
+		}
+		if annotations, ok := objMeta["annotations"].(map[string]interface{}); ok && len(annotations) > 0 {
+			metadata["annotations"] = annotations
+		}
+	}
+	fields["metadata"] = metadata
+	return fields, nil
+}
+
+// diffPaths returns dot-separated paths of the values that differ. Lists are
+// compared as a whole.
+func diffPaths(prefix string, a, b interface{}) []string {
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+	if !aIsMap || !bIsMap {
+		if reflect.DeepEqual(a, b) {
+			return nil
+		}
+		return []string{prefix}
+	}
+
+	var paths []string
+	keys := map[string]bool{}
+	for key := range aMap {
+		keys[key] = true
+	}
+	for key := range bMap {
+		keys[key] = true
+	}
+	for key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		paths = append(paths, diffPaths(path, aMap[key], bMap[key])...)
+	}
+	return paths
+}
@@ -0,0 +1,19 @@
+package staticresource
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var staticResourceReverts = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Name:           "aws_efs_csi_driver_operator_static_resource_reverts_total",
+		Help:           "Number of times a static resource managed by the operator was changed by someone else and reverted to its asset.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"kind", "namespace", "name"},
+)
+
+func init() {
+	legacyregistry.MustRegister(staticResourceReverts)
+}
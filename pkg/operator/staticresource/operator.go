@@ -2,6 +2,8 @@ package staticresource
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	opv1 "github.com/openshift/api/operator/v1"
@@ -9,49 +11,38 @@ import (
 	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/library-go/pkg/operator/management"
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourcehelper"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
 	operatorv1helpers "github.com/openshift/library-go/pkg/operator/v1helpers"
-	corev1 "k8s.io/api/core/v1"
-	rbacv1 "k8s.io/api/rbac/v1"
-	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 )
 
-type SyncObjects struct {
-	CSIDriver      *storagev1.CSIDriver
-	PrivilegedRole *rbacv1.ClusterRole
-	CAConfigMap    *corev1.ConfigMap
-
-	NodeServiceAccount *corev1.ServiceAccount
-	NodeRoleBinding    *rbacv1.ClusterRoleBinding
-
-	ControllerServiceAccount *corev1.ServiceAccount
-	ControllerRoleBinding    *rbacv1.ClusterRoleBinding
-	ProvisionerRoleBinding   *rbacv1.ClusterRoleBinding
-
-	PrometheusRole        *rbacv1.Role
-	PrometheusRoleBinding *rbacv1.RoleBinding
-	MetricsService        *corev1.Service
-	RBACProxyRole         *rbacv1.ClusterRole
-	RBACProxyRoleBinding  *rbacv1.ClusterRoleBinding
-
-	LeaseLeaderElectionRole        *rbacv1.Role
-	LeaseLeaderElectionRoleBinding *rbacv1.RoleBinding
-}
-
 // CSIStaticResourceController creates, manages and deletes static resources of a CSI driver, such as RBAC rules.
-// It's more hardcoded variant of library-go's StaticResourceController, which does not implement removal
+// It's a variant of library-go's StaticResourceController, which does not implement removal
 // of objects yet.
+// The objects are read from asset files, applied in the order of the files and removed in the reverse order.
+// Applied objects are labeled as owned by the controller, owned objects that are not in the assets anymore
+// are removed. Objects read from deleteOnlyFiles are applied by other controllers and only removed here.
 type CSIStaticResourceController struct {
 	operatorName      string
 	operatorNamespace string
 	operatorClient    operatorv1helpers.OperatorClientWithFinalizers
 	kubeClient        kubernetes.Interface
 	eventRecorder     events.Recorder
-	objs              SyncObjects
+	// version of the operator, objects are labeled with it
+	version string
+	objs    []runtime.Object
+	// deleteOnlyObjs are applied by other controllers, they're only removed here.
+	deleteOnlyObjs []runtime.Object
+	listers        *objectListers
+	driftTracker   *driftTracker
 }
 
 func NewCSIStaticResourceController(
@@ -61,7 +52,10 @@ func NewCSIStaticResourceController(
 	kubeClient kubernetes.Interface,
 	informers operatorv1helpers.KubeInformersForNamespaces,
 	recorder events.Recorder,
-	objs SyncObjects,
+	version string,
+	assetFunc resourceapply.AssetFunc,
+	files []string,
+	deleteOnlyFiles []string,
 ) factory.Controller {
 	c := &CSIStaticResourceController{
 		operatorName:      name,
@@ -69,19 +63,29 @@ func NewCSIStaticResourceController(
 		operatorClient:    operatorClient,
 		kubeClient:        kubeClient,
 		eventRecorder:     recorder,
-		objs:              objs,
+		version:           version,
+		objs:              mustReadAssets(assetFunc, files),
+		deleteOnlyObjs:    mustReadAssets(assetFunc, deleteOnlyFiles),
+		listers:           newObjectListers(informers.InformersFor(operatorNamespace)),
+		driftTracker:      newDriftTracker(clock.RealClock{}),
+	}
+	if errs := validation.IsValidLabelValue(version); len(errs) > 0 {
+		klog.Warningf("Operator version %q is not a valid label value, not labeling objects with it: %s", version, strings.Join(errs, ", "))
+		c.version = ""
 	}
 
 	operatorInformers := []factory.Informer{
 		operatorClient.Informer(),
 		informers.InformersFor(operatorNamespace).Core().V1().ServiceAccounts().Informer(),
 		informers.InformersFor(operatorNamespace).Storage().V1().CSIDrivers().Informer(),
+		informers.InformersFor(operatorNamespace).Storage().V1().StorageClasses().Informer(),
 		informers.InformersFor(operatorNamespace).Rbac().V1().ClusterRoles().Informer(),
 		informers.InformersFor(operatorNamespace).Rbac().V1().ClusterRoleBindings().Informer(),
 		informers.InformersFor(operatorNamespace).Rbac().V1().Roles().Informer(),
 		informers.InformersFor(operatorNamespace).Rbac().V1().RoleBindings().Informer(),
 		informers.InformersFor(operatorNamespace).Core().V1().Services().Informer(),
 		informers.InformersFor(operatorNamespace).Core().V1().ConfigMaps().Informer(),
+		informers.InformersFor(operatorNamespace).Networking().V1().NetworkPolicies().Informer(),
 	}
 	return factory.New().
 		WithSyncDegradedOnError(operatorClient).
@@ -91,6 +95,31 @@ func NewCSIStaticResourceController(
 		ToController(name, recorder.WithComponentSuffix("csi-static-resource-controller"))
 }
 
+// readAssets decodes the asset files into typed objects, in the order of the files.
+func readAssets(assetFunc resourceapply.AssetFunc, files []string) ([]runtime.Object, error) {
+	var objs []runtime.Object
+	for _, file := range files {
+		objBytes, err := assetFunc(file)
+		if err != nil {
+			return nil, fmt.Errorf("missing %q: %w", file, err)
+		}
+		obj, err := resourceread.ReadGenericWithUnstructured(objBytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode %q: %w", file, err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+func mustReadAssets(assetFunc resourceapply.AssetFunc, files []string) []runtime.Object {
+	objs, err := readAssets(assetFunc, files)
+	if err != nil {
+		panic(err)
+	}
+	return objs
+}
+
 func (c *CSIStaticResourceController) sync(ctx context.Context, controllerContext factory.SyncContext) error {
 	opSpec, opStatus, _, err := c.operatorClient.GetOperatorState()
 	if apierrors.IsNotFound(err) {
@@ -122,208 +151,118 @@ func (c *CSIStaticResourceController) syncManaged(ctx context.Context, opSpec *o
 	}
 
 	var errs []error
-	// Common
-	_, _, err = resourceapply.ApplyCSIDriver(ctx, c.kubeClient.StorageV1(), c.eventRecorder, c.objs.CSIDriver)
-	if err != nil {
-		errs = append(errs, err)
-	}
-	_, _, err = resourceapply.ApplyClusterRole(ctx, c.kubeClient.RbacV1(), c.eventRecorder, c.objs.PrivilegedRole)
-	if err != nil {
-		errs = append(errs, err)
-	}
-	_, _, err = resourceapply.ApplyConfigMap(ctx, c.kubeClient.CoreV1(), c.eventRecorder, c.objs.CAConfigMap)
-	if err != nil {
-		errs = append(errs, err)
+	var results []applyResult
+	for _, obj := range c.objs {
+		result := c.applyObject(ctx, c.withOwnerLabels(obj))
+		if result.err != nil {
+			errs = append(errs, result.err)
+		}
+		results = append(results, result)
 	}
-
-	// Node
-	_, _, err = resourceapply.ApplyServiceAccount(ctx, c.kubeClient.CoreV1(), c.eventRecorder, c.objs.NodeServiceAccount)
+	removed, err := c.deleteOrphans(ctx)
 	if err != nil {
 		errs = append(errs, err)
 	}
-	_, _, err = resourceapply.ApplyClusterRoleBinding(ctx, c.kubeClient.RbacV1(), c.eventRecorder, c.objs.NodeRoleBinding)
+	_, _, err = operatorv1helpers.UpdateStatus(ctx, c.operatorClient,
+		updateObjectStatusFn(results, removed),
+		operatorv1helpers.UpdateConditionFn(c.driftingCondition()),
+	)
 	if err != nil {
 		errs = append(errs, err)
 	}
+	return errors.NewAggregate(errs)
+}
 
-	// Controller
-	_, _, err = resourceapply.ApplyServiceAccount(ctx, c.kubeClient.CoreV1(), c.eventRecorder, c.objs.ControllerServiceAccount)
-	if err != nil {
-		errs = append(errs, err)
-	}
-	_, _, err = resourceapply.ApplyClusterRoleBinding(ctx, c.kubeClient.RbacV1(), c.eventRecorder, c.objs.ControllerRoleBinding)
-	if err != nil {
-		errs = append(errs, err)
-	}
-	_, _, err = resourceapply.ApplyClusterRoleBinding(ctx, c.kubeClient.RbacV1(), c.eventRecorder, c.objs.ProvisionerRoleBinding)
-	if err != nil {
-		errs = append(errs, err)
-	}
-	_, _, err = resourceapply.ApplyRole(ctx, c.kubeClient.RbacV1(), c.eventRecorder, c.objs.LeaseLeaderElectionRole)
-	if err != nil {
-		errs = append(errs, err)
-	}
-	_, _, err = resourceapply.ApplyRoleBinding(ctx, c.kubeClient.RbacV1(), c.eventRecorder, c.objs.LeaseLeaderElectionRoleBinding)
-	if err != nil {
-		errs = append(errs, err)
+// applyObject applies the object and reports when it reverted changes of the
+// live object. The state before apply is read from the informer cache, a change
+// the cache has not seen yet is reverted without a report.
+func (c *CSIStaticResourceController) applyObject(ctx context.Context, required runtime.Object) applyResult {
+	result := applyResult{required: required}
+	live, err := c.listers.get(required)
+	if apierrors.IsNotFound(err) {
+		live = nil
+	} else if err != nil {
+		result.err = err
+		return result
 	}
 
-	// Metrics
-	_, _, err = resourceapply.ApplyRole(ctx, c.kubeClient.RbacV1(), c.eventRecorder, c.objs.PrometheusRole)
+	applied, changed, err := applyObject(ctx, c.kubeClient, c.eventRecorder, required)
 	if err != nil {
-		errs = append(errs, err)
-	}
-	_, _, err = resourceapply.ApplyRoleBinding(ctx, c.kubeClient.RbacV1(), c.eventRecorder, c.objs.PrometheusRoleBinding)
-	if err != nil {
-		errs = append(errs, err)
-	}
-	_, _, err = resourceapply.ApplyService(ctx, c.kubeClient.CoreV1(), c.eventRecorder, c.objs.MetricsService)
-	if err != nil {
-		errs = append(errs, err)
+		result.err = err
+		return result
 	}
-	_, _, err = resourceapply.ApplyClusterRole(ctx, c.kubeClient.RbacV1(), c.eventRecorder, c.objs.RBACProxyRole)
-	if err != nil {
-		errs = append(errs, err)
+	result.applied = applied
+	if changed && live != nil {
+		result.err = c.reportRevert(live, applied)
 	}
-	_, _, err = resourceapply.ApplyClusterRoleBinding(ctx, c.kubeClient.RbacV1(), c.eventRecorder, c.objs.RBACProxyRoleBinding)
-	if err != nil {
-		errs = append(errs, err)
-	}
-
-	return errors.NewAggregate(errs)
+	return result
 }
 
 func (c *CSIStaticResourceController) syncDeleting(ctx context.Context, opSpec *opv1.OperatorSpec, opStatus *opv1.OperatorStatus, controllerContext factory.SyncContext) error {
 	var errs []error
+	var removed []runtime.Object
 
-	// Common
-	if err := c.kubeClient.StorageV1().CSIDrivers().Delete(ctx, c.objs.CSIDriver.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
+	// Remove in the reverse order, the objects applied last may depend on the ones applied first
+	for i := len(c.deleteOnlyObjs) - 1; i >= 0; i-- {
+		owned, err := c.isDeleteOnlyObjectOwned(c.deleteOnlyObjs[i])
+		if err != nil {
 			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("CSIDriver %s already removed", c.objs.CSIDriver.Name)
+			continue
 		}
-	}
-
-	if err := c.kubeClient.RbacV1().ClusterRoles().Delete(ctx, c.objs.PrivilegedRole.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("ClusterRole %s already removed", c.objs.PrivilegedRole.Name)
+		if !owned {
+			klog.V(4).Infof("%s was not applied by the operator, not removing it", resourcehelper.FormatResourceForCLIWithNamespace(c.deleteOnlyObjs[i]))
+			continue
 		}
-	}
-
-	if err := c.kubeClient.CoreV1().ConfigMaps(c.operatorNamespace).Delete(ctx, c.objs.CAConfigMap.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
+		if err := c.deleteObject(ctx, c.deleteOnlyObjs[i]); err != nil {
 			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("ConfigMap %s already removed", c.objs.CAConfigMap.Name)
 		}
 	}
-
-	// Node
-	if err := c.kubeClient.CoreV1().ServiceAccounts(c.operatorNamespace).Delete(ctx, c.objs.NodeServiceAccount.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
+	for i := len(c.objs) - 1; i >= 0; i-- {
+		if err := c.deleteObject(ctx, c.objs[i]); err != nil {
 			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("ServiceAccount %s already removed", c.objs.NodeServiceAccount.Name)
+			continue
 		}
+		removed = append(removed, c.objs[i])
 	}
-
-	if err := c.kubeClient.RbacV1().ClusterRoleBindings().Delete(ctx, c.objs.NodeRoleBinding.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("ClusterRoleBinding %s already removed", c.objs.NodeRoleBinding.Name)
-		}
-	}
-
-	// Controller
-	if err := c.kubeClient.CoreV1().ServiceAccounts(c.operatorNamespace).Delete(ctx, c.objs.ControllerServiceAccount.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("ServiceAccount %s already removed", c.objs.ControllerServiceAccount.Name)
-		}
-	}
-
-	if err := c.kubeClient.RbacV1().ClusterRoleBindings().Delete(ctx, c.objs.ControllerRoleBinding.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("ClusterRoleBinding %s already removed", c.objs.ControllerRoleBinding.Name)
-		}
-	}
-
-	if err := c.kubeClient.RbacV1().ClusterRoleBindings().Delete(ctx, c.objs.ProvisionerRoleBinding.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("ClusterRoleBinding %s already removed", c.objs.ProvisionerRoleBinding.Name)
-		}
-	}
-
-	if err := c.kubeClient.RbacV1().Roles(c.operatorNamespace).Delete(ctx, c.objs.LeaseLeaderElectionRole.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("Role %s already removed", c.objs.LeaseLeaderElectionRole.Name)
-		}
+	if _, _, err := operatorv1helpers.UpdateStatus(ctx, c.operatorClient, removeObjectStatusFn(removed)); err != nil {
+		errs = append(errs, err)
 	}
 
-	if err := c.kubeClient.RbacV1().RoleBindings(c.operatorNamespace).Delete(ctx, c.objs.LeaseLeaderElectionRoleBinding.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("RoleBinding %s already removed", c.objs.LeaseLeaderElectionRoleBinding.Name)
-		}
+	if err := errors.NewAggregate(errs); err != nil {
+		return err
 	}
 
-	// Metrics
-	if err := c.kubeClient.RbacV1().Roles(c.operatorNamespace).Delete(ctx, c.objs.PrometheusRole.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("Role %s already removed", c.objs.PrometheusRole.Name)
-		}
-	}
+	// All removed, remove the finalizer as the last step
+	return operatorv1helpers.RemoveFinalizer(ctx, c.operatorClient, c.operatorName)
+}
 
-	if err := c.kubeClient.RbacV1().RoleBindings(c.operatorNamespace).Delete(ctx, c.objs.PrometheusRoleBinding.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("RoleBinding %s already removed", c.objs.PrometheusRoleBinding.Name)
-		}
+// deleteObject removes the object, an already removed object is not an error.
+func (c *CSIStaticResourceController) deleteObject(ctx context.Context, obj runtime.Object) error {
+	err := deleteObject(ctx, c.kubeClient, obj)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).Infof("%s already removed", resourcehelper.FormatResourceForCLIWithNamespace(obj))
+		return nil
 	}
+	return err
+}
 
-	if err := c.kubeClient.CoreV1().Services(c.operatorNamespace).Delete(ctx, c.objs.MetricsService.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("Service %s already removed", c.objs.MetricsService.Name)
-		}
+// isDeleteOnlyObjectOwned checks that the live object carries all labels of
+// its asset. Objects of other controllers are not labeled with ownerLabel,
+// the labels of the asset tell them apart from an object with the same name
+// created by a user.
+func (c *CSIStaticResourceController) isDeleteOnlyObjectOwned(obj runtime.Object) (bool, error) {
+	live, err := c.listers.get(obj)
+	if apierrors.IsNotFound(err) {
+		return false, nil
 	}
-
-	if err := c.kubeClient.RbacV1().ClusterRoles().Delete(ctx, c.objs.RBACProxyRole.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("ClusterRole %s already removed", c.objs.RBACProxyRole.Name)
-		}
+	if err != nil {
+		return false, err
 	}
-
-	if err := c.kubeClient.RbacV1().ClusterRoleBindings().Delete(ctx, c.objs.RBACProxyRoleBinding.Name, metav1.DeleteOptions{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			errs = append(errs, err)
-		} else {
-			klog.V(4).Infof("ClusterRoleBinding %s already removed", c.objs.RBACProxyRoleBinding.Name)
+	liveLabels := live.(metav1.Object).GetLabels()
+	for key, value := range obj.(metav1.Object).GetLabels() {
+		if liveLabels[key] != value {
+			return false, nil
 		}
 	}
-
-	if err := errors.NewAggregate(errs); err != nil {
-		return err
-	}
-
-	// All removed, remove the finalizer as the last step
-	return operatorv1helpers.RemoveFinalizer(ctx, c.operatorClient, c.operatorName)
+	return true, nil
 }
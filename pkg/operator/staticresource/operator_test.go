@@ -0,0 +1,1229 @@
+package staticresource
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	operatorv1helpers "github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+const (
+	testNamespace      = "test-namespace"
+	testControllerName = "TestStaticResourceController"
+	testOperatorName   = "test"
+	testVersion        = "4.16.0"
+	// testFinalizer is the finalizer library-go computes from $OPERATOR_NAME and the controller name.
+	testFinalizer = testOperatorName + ".operator.openshift.io/" + testControllerName
+)
+
+// testAssets has a minimal manifest of every object of the controller and of
+// every kind it handles.
+var testAssets = map[string]string{
+	"csidriver.yaml": `
+apiVersion: storage.k8s.io/v1
+kind: CSIDriver
+metadata:
+  name: efs.csi.aws.com
+`,
+	"privileged_role.yaml": `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: privileged-role
+`,
+	"cabundle_cm.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ca-bundle
+  namespace: test-namespace
+`,
+	"node_sa.yaml": `
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: node-sa
+  namespace: test-namespace
+`,
+	"node_privileged_binding.yaml": `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: node-privileged-binding
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: privileged-role
+`,
+	"controller_sa.yaml": `
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: controller-sa
+  namespace: test-namespace
+`,
+	"controller_privileged_binding.yaml": `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: controller-privileged-binding
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: privileged-role
+`,
+	"provisioner_binding.yaml": `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: provisioner-binding
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: external-provisioner-runner
+`,
+	"prometheus_role.yaml": `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: prometheus
+  namespace: test-namespace
+`,
+	"prometheus_rolebinding.yaml": `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: prometheus
+  namespace: test-namespace
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: prometheus
+`,
+	"service.yaml": `
+apiVersion: v1
+kind: Service
+metadata:
+  name: controller-metrics
+  namespace: test-namespace
+`,
+	"operator_service.yaml": `
+apiVersion: v1
+kind: Service
+metadata:
+  name: operator-metrics
+  namespace: test-namespace
+`,
+	"rbac_proxy_role.yaml": `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: kube-rbac-proxy-role
+`,
+	"rbac_proxy_binding.yaml": `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: kube-rbac-proxy-binding
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: kube-rbac-proxy-role
+`,
+	"lease_leader_election_role.yaml": `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: lease-leader-election
+  namespace: test-namespace
+`,
+	"lease_leader_election_rolebinding.yaml": `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: lease-leader-election
+  namespace: test-namespace
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: lease-leader-election
+`,
+	"networkpolicy.yaml": `
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: allow-metrics
+  namespace: test-namespace
+spec:
+  podSelector: {}
+`,
+	"storageclass.yaml": `
+apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: efs-sc
+  labels:
+    managed-storageclass: "true"
+provisioner: efs.csi.aws.com
+`,
+	"invalid.yaml": `
+apiVersion: v1
+kind: Foo
+metadata: [
+`,
+}
+
+// testFiles are the asset files of the controller, in the order they're applied.
+var testFiles = []string{
+	"csidriver.yaml",
+	"privileged_role.yaml",
+	"cabundle_cm.yaml",
+	"node_sa.yaml",
+	"node_privileged_binding.yaml",
+	"controller_sa.yaml",
+	"controller_privileged_binding.yaml",
+	"provisioner_binding.yaml",
+	"prometheus_role.yaml",
+	"prometheus_rolebinding.yaml",
+	"service.yaml",
+	"operator_service.yaml",
+	"rbac_proxy_role.yaml",
+	"rbac_proxy_binding.yaml",
+	"lease_leader_election_role.yaml",
+	"lease_leader_election_rolebinding.yaml",
+}
+
+// testDeleteOnlyFiles are the asset files of objects applied by other controllers.
+var testDeleteOnlyFiles = []string{
+	"storageclass.yaml",
+}
+
+func testAssetFunc(name string) ([]byte, error) {
+	asset, ok := testAssets[name]
+	if !ok {
+		return nil, fmt.Errorf("asset %s not found", name)
+	}
+	return []byte(asset), nil
+}
+
+// testObjects are the objects read from testFiles.
+type testObjects struct {
+	CSIDriver                      *storagev1.CSIDriver
+	PrivilegedRole                 *rbacv1.ClusterRole
+	CAConfigMap                    *corev1.ConfigMap
+	NodeServiceAccount             *corev1.ServiceAccount
+	NodeRoleBinding                *rbacv1.ClusterRoleBinding
+	ControllerServiceAccount       *corev1.ServiceAccount
+	ControllerRoleBinding          *rbacv1.ClusterRoleBinding
+	ProvisionerRoleBinding         *rbacv1.ClusterRoleBinding
+	PrometheusRole                 *rbacv1.Role
+	PrometheusRoleBinding          *rbacv1.RoleBinding
+	MetricsService                 *corev1.Service
+	OperatorMetricsService         *corev1.Service
+	RBACProxyRole                  *rbacv1.ClusterRole
+	RBACProxyRoleBinding           *rbacv1.ClusterRoleBinding
+	LeaseLeaderElectionRole        *rbacv1.Role
+	LeaseLeaderElectionRoleBinding *rbacv1.RoleBinding
+}
+
+func newTestObjects() testObjects {
+	objs := mustReadAssets(testAssetFunc, testFiles)
+	return testObjects{
+		CSIDriver:                      objs[0].(*storagev1.CSIDriver),
+		PrivilegedRole:                 objs[1].(*rbacv1.ClusterRole),
+		CAConfigMap:                    objs[2].(*corev1.ConfigMap),
+		NodeServiceAccount:             objs[3].(*corev1.ServiceAccount),
+		NodeRoleBinding:                objs[4].(*rbacv1.ClusterRoleBinding),
+		ControllerServiceAccount:       objs[5].(*corev1.ServiceAccount),
+		ControllerRoleBinding:          objs[6].(*rbacv1.ClusterRoleBinding),
+		ProvisionerRoleBinding:         objs[7].(*rbacv1.ClusterRoleBinding),
+		PrometheusRole:                 objs[8].(*rbacv1.Role),
+		PrometheusRoleBinding:          objs[9].(*rbacv1.RoleBinding),
+		MetricsService:                 objs[10].(*corev1.Service),
+		OperatorMetricsService:         objs[11].(*corev1.Service),
+		RBACProxyRole:                  objs[12].(*rbacv1.ClusterRole),
+		RBACProxyRoleBinding:           objs[13].(*rbacv1.ClusterRoleBinding),
+		LeaseLeaderElectionRole:        objs[14].(*rbacv1.Role),
+		LeaseLeaderElectionRoleBinding: objs[15].(*rbacv1.RoleBinding),
+	}
+}
+
+// allObjects returns all objects of testObjects, in the order of testFiles.
+func allObjects(objs testObjects) []runtime.Object {
+	return []runtime.Object{
+		objs.CSIDriver, objs.PrivilegedRole, objs.CAConfigMap,
+		objs.NodeServiceAccount, objs.NodeRoleBinding,
+		objs.ControllerServiceAccount, objs.ControllerRoleBinding, objs.ProvisionerRoleBinding,
+		objs.PrometheusRole, objs.PrometheusRoleBinding, objs.MetricsService, objs.OperatorMetricsService,
+		objs.RBACProxyRole, objs.RBACProxyRoleBinding,
+		objs.LeaseLeaderElectionRole, objs.LeaseLeaderElectionRoleBinding,
+	}
+}
+
+// objectKeys returns sorted <resource>/<name> of the objects, as used by actionKeys.
+func objectKeys(objs []runtime.Object) []string {
+	keys := orderedObjectKeys(objs)
+	sort.Strings(keys)
+	return keys
+}
+
+// orderedObjectKeys returns <resource>/<name> of the objects, in the order of the objects.
+func orderedObjectKeys(objs []runtime.Object) []string {
+	var keys []string
+	for _, obj := range objs {
+		accessor, err := metaAccessor(obj)
+		if err != nil {
+			panic(err)
+		}
+		keys = append(keys, fmt.Sprintf("%s/%s", resourceFor(obj).Resource, accessor.GetName()))
+	}
+	return keys
+}
+
+func metaAccessor(obj runtime.Object) (metav1.Object, error) {
+	accessor, ok := obj.(metav1.Object)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a metav1.Object", obj)
+	}
+	return accessor, nil
+}
+
+// resourceFor returns the resource of the object.
+func resourceFor(obj runtime.Object) schema.GroupVersionResource {
+	switch obj.(type) {
+	case *storagev1.CSIDriver:
+		return storagev1.SchemeGroupVersion.WithResource("csidrivers")
+	case *storagev1.StorageClass:
+		return storagev1.SchemeGroupVersion.WithResource("storageclasses")
+	case *rbacv1.ClusterRole:
+		return rbacv1.SchemeGroupVersion.WithResource("clusterroles")
+	case *rbacv1.ClusterRoleBinding:
+		return rbacv1.SchemeGroupVersion.WithResource("clusterrolebindings")
+	case *rbacv1.Role:
+		return rbacv1.SchemeGroupVersion.WithResource("roles")
+	case *rbacv1.RoleBinding:
+		return rbacv1.SchemeGroupVersion.WithResource("rolebindings")
+	case *corev1.ConfigMap:
+		return corev1.SchemeGroupVersion.WithResource("configmaps")
+	case *corev1.ServiceAccount:
+		return corev1.SchemeGroupVersion.WithResource("serviceaccounts")
+	case *corev1.Service:
+		return corev1.SchemeGroupVersion.WithResource("services")
+	case *networkingv1.NetworkPolicy:
+		return networkingv1.SchemeGroupVersion.WithResource("networkpolicies")
+	}
+	panic(fmt.Sprintf("unexpected object %T", obj))
+}
+
+// actionKeys returns sorted <resource>/<name> of all actions with the verb.
+func actionKeys(actions []core.Action, verb string) []string {
+	keys := orderedActionKeys(actions, verb)
+	sort.Strings(keys)
+	return keys
+}
+
+// orderedActionKeys returns <resource>/<name> of all actions with the verb, in the order of the actions.
+func orderedActionKeys(actions []core.Action, verb string) []string {
+	var keys []string
+	for _, action := range actions {
+		if action.GetVerb() != verb {
+			continue
+		}
+		name := ""
+		switch a := action.(type) {
+		case core.CreateAction:
+			name = a.GetObject().(metav1.Object).GetName()
+		case core.UpdateAction:
+			name = a.GetObject().(metav1.Object).GetName()
+		case core.DeleteAction:
+			name = a.GetName()
+		}
+		keys = append(keys, fmt.Sprintf("%s/%s", action.GetResource().Resource, name))
+	}
+	return keys
+}
+
+type testContext struct {
+	controller     *CSIStaticResourceController
+	kubeClient     *fake.Clientset
+	operatorClient operatorv1helpers.OperatorClientWithFinalizers
+	informers      kubeinformers.SharedInformerFactory
+	clock          *clocktesting.FakePassiveClock
+}
+
+func newTestContext(objs testObjects, managementState opv1.ManagementState, meta *metav1.ObjectMeta, existing ...runtime.Object) *testContext {
+	kubeClient := fake.NewSimpleClientset(existing...)
+	operatorClient := operatorv1helpers.NewFakeOperatorClientWithObjectMeta(
+		meta,
+		&opv1.OperatorSpec{ManagementState: managementState},
+		&opv1.OperatorStatus{},
+		nil,
+	)
+	// The informers are not started, syncInformers fills their caches
+	informers := kubeinformers.NewSharedInformerFactory(kubeClient, 0)
+	clock := clocktesting.NewFakePassiveClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	controller := &CSIStaticResourceController{
+		operatorName:      testControllerName,
+		operatorNamespace: testNamespace,
+		operatorClient:    operatorClient,
+		kubeClient:        kubeClient,
+		eventRecorder:     events.NewInMemoryRecorder(testControllerName),
+		version:           testVersion,
+		objs:              allObjects(objs),
+		deleteOnlyObjs:    mustReadAssets(testAssetFunc, testDeleteOnlyFiles),
+		listers:           newObjectListers(informers),
+		driftTracker:      newDriftTracker(clock),
+	}
+	return &testContext{
+		controller:     controller,
+		kubeClient:     kubeClient,
+		operatorClient: operatorClient,
+		informers:      informers,
+		clock:          clock,
+	}
+}
+
+// syncInformers fills the informer caches with the objects in the tracker of
+// the fake client, as if the informers were synced.
+func (c *testContext) syncInformers() {
+	kinds := []struct {
+		informer cache.SharedIndexInformer
+		gvk      schema.GroupVersionKind
+	}{
+		{c.informers.Storage().V1().CSIDrivers().Informer(), storagev1.SchemeGroupVersion.WithKind("CSIDriver")},
+		{c.informers.Storage().V1().StorageClasses().Informer(), storagev1.SchemeGroupVersion.WithKind("StorageClass")},
+		{c.informers.Rbac().V1().ClusterRoles().Informer(), rbacv1.SchemeGroupVersion.WithKind("ClusterRole")},
+		{c.informers.Rbac().V1().ClusterRoleBindings().Informer(), rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding")},
+		{c.informers.Rbac().V1().Roles().Informer(), rbacv1.SchemeGroupVersion.WithKind("Role")},
+		{c.informers.Rbac().V1().RoleBindings().Informer(), rbacv1.SchemeGroupVersion.WithKind("RoleBinding")},
+		{c.informers.Core().V1().ServiceAccounts().Informer(), corev1.SchemeGroupVersion.WithKind("ServiceAccount")},
+		{c.informers.Core().V1().ConfigMaps().Informer(), corev1.SchemeGroupVersion.WithKind("ConfigMap")},
+		{c.informers.Core().V1().Services().Informer(), corev1.SchemeGroupVersion.WithKind("Service")},
+		{c.informers.Networking().V1().NetworkPolicies().Informer(), networkingv1.SchemeGroupVersion.WithKind("NetworkPolicy")},
+	}
+	for _, kind := range kinds {
+		gvr, _ := meta.UnsafeGuessKindToResource(kind.gvk)
+		list, err := c.kubeClient.Tracker().List(gvr, kind.gvk, "")
+		if err != nil {
+			panic(err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			panic(err)
+		}
+		var objs []interface{}
+		for _, item := range items {
+			objs = append(objs, item)
+		}
+		if err := kind.informer.GetIndexer().Replace(objs, ""); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (c *testContext) sync() error {
+	c.syncInformers()
+	syncCtx := factory.NewSyncContext(testControllerName, events.NewInMemoryRecorder(testControllerName))
+	return c.controller.sync(context.TODO(), syncCtx)
+}
+
+func (c *testContext) finalizers() []string {
+	meta, err := c.operatorClient.GetObjectMeta()
+	if err != nil {
+		panic(err)
+	}
+	return meta.Finalizers
+}
+
+// setStatus updates status of the operator with the function.
+func (c *testContext) setStatus(t *testing.T, update func(status *opv1.OperatorStatus)) {
+	t.Helper()
+	_, status, resourceVersion, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status = status.DeepCopy()
+	update(status)
+	if _, err := c.operatorClient.UpdateOperatorStatus(context.TODO(), resourceVersion, status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// objectStatus returns sorted <resource>/<name> of status.generations and
+// sorted types of per-object conditions.
+func (c *testContext) objectStatus(t *testing.T) ([]string, []string) {
+	t.Helper()
+	_, status, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var generations, conditions []string
+	for _, generation := range status.Generations {
+		generations = append(generations, generation.Resource+"/"+generation.Name)
+	}
+	for _, condition := range status.Conditions {
+		if strings.HasPrefix(condition.Type, objectFailingConditionPrefix+"_") {
+			conditions = append(conditions, condition.Type)
+		}
+	}
+	sort.Strings(generations)
+	sort.Strings(conditions)
+	return generations, conditions
+}
+
+// failOn makes the fake client return an error for the verb on the resource.
+func failOn(client *fake.Clientset, verb, resource string) {
+	client.PrependReactor(verb, resource, func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("injected %s %s error", verb, resource)
+	})
+}
+
+func checkError(t *testing.T, err error, expectedErr string) {
+	t.Helper()
+	if expectedErr == "" {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), expectedErr) {
+		t.Errorf("expected error containing %q, got %v", expectedErr, err)
+	}
+}
+
+func TestSyncManaged(t *testing.T) {
+	objs := newTestObjects()
+	applied := allObjects(objs)
+
+	tests := []struct {
+		name            string
+		managementState opv1.ManagementState
+		// synced runs one sync before the tested one, so all objects exist
+		synced bool
+		failOn [][]string
+		// expected results
+		expectedErr        string
+		expectedCreated    []string
+		expectedExisting   []string
+		expectedFinalizers []string
+	}{
+		{
+			name:               "new objects",
+			managementState:    opv1.Managed,
+			expectedCreated:    objectKeys(applied),
+			expectedExisting:   objectKeys(applied),
+			expectedFinalizers: []string{testFinalizer},
+		},
+		{
+			name:               "existing objects",
+			managementState:    opv1.Managed,
+			synced:             true,
+			expectedExisting:   objectKeys(applied),
+			expectedFinalizers: []string{testFinalizer},
+		},
+		{
+			name:            "unmanaged",
+			managementState: opv1.Unmanaged,
+		},
+		{
+			name:            "removed",
+			managementState: opv1.Removed,
+		},
+		{
+			name:            "apply error",
+			managementState: opv1.Managed,
+			failOn:          [][]string{{"create", "clusterroles"}},
+			expectedErr:     "injected create clusterroles error",
+			expectedCreated: objectKeys(applied),
+			// All other objects are still applied
+			expectedExisting: objectKeys([]runtime.Object{
+				objs.CSIDriver, objs.CAConfigMap,
+				objs.NodeServiceAccount, objs.NodeRoleBinding,
+				objs.ControllerServiceAccount, objs.ControllerRoleBinding, objs.ProvisionerRoleBinding,
+				objs.PrometheusRole, objs.PrometheusRoleBinding, objs.MetricsService, objs.OperatorMetricsService,
+				objs.RBACProxyRoleBinding,
+				objs.LeaseLeaderElectionRole, objs.LeaseLeaderElectionRoleBinding,
+			}),
+			expectedFinalizers: []string{testFinalizer},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("OPERATOR_NAME", testOperatorName)
+			c := newTestContext(objs, test.managementState, nil)
+			if test.synced {
+				if err := c.sync(); err != nil {
+					t.Fatalf("unexpected error in the initial sync: %v", err)
+				}
+				c.kubeClient.ClearActions()
+			}
+			for _, f := range test.failOn {
+				failOn(c.kubeClient, f[0], f[1])
+			}
+
+			err := c.sync()
+			checkError(t, err, test.expectedErr)
+
+			// Failed creates are recorded as actions too
+			created := actionKeys(c.kubeClient.Actions(), "create")
+			if !reflect.DeepEqual(created, test.expectedCreated) {
+				t.Errorf("expected created objects %v, got %v", test.expectedCreated, created)
+			}
+			if deleted := actionKeys(c.kubeClient.Actions(), "delete"); len(deleted) > 0 {
+				t.Errorf("expected no deleted objects, got %v", deleted)
+			}
+			if existing := existingKeys(c.kubeClient, allObjects(objs)); !reflect.DeepEqual(existing, test.expectedExisting) {
+				t.Errorf("expected existing objects %v, got %v", test.expectedExisting, existing)
+			}
+			if !reflect.DeepEqual(c.finalizers(), test.expectedFinalizers) {
+				t.Errorf("expected finalizers %v, got %v", test.expectedFinalizers, c.finalizers())
+			}
+		})
+	}
+}
+
+func TestSyncDeleting(t *testing.T) {
+	objs := newTestObjects()
+	all := allObjects(objs)
+	storageClass := mustReadAssets(testAssetFunc, testDeleteOnlyFiles)[0].(*storagev1.StorageClass)
+	userStorageClass := storageClass.DeepCopy()
+	userStorageClass.Labels = nil
+
+	tests := []struct {
+		name     string
+		existing []runtime.Object
+		failOn   [][]string
+		// expected results
+		expectedErr                 string
+		expectedDeletedStorageClass bool
+		expectedRemaining           []string
+		expectedFinalizers          []string
+	}{
+		{
+			name:                        "all objects exist",
+			existing:                    append(all, storageClass),
+			expectedDeletedStorageClass: true,
+			expectedFinalizers:          []string{"other"},
+		},
+		{
+			name: "some objects already removed",
+			existing: []runtime.Object{
+				objs.CSIDriver, objs.NodeServiceAccount, objs.PrometheusRole,
+			},
+			expectedFinalizers: []string{"other"},
+		},
+		{
+			name:               "all objects already removed",
+			expectedFinalizers: []string{"other"},
+		},
+		{
+			name:               "StorageClass created by a user is kept",
+			existing:           append(all, userStorageClass),
+			expectedRemaining:  objectKeys([]runtime.Object{storageClass}),
+			expectedFinalizers: []string{"other"},
+		},
+		{
+			name:        "delete error",
+			existing:    all,
+			failOn:      [][]string{{"delete", "clusterrolebindings"}},
+			expectedErr: "injected delete clusterrolebindings error",
+			expectedRemaining: objectKeys([]runtime.Object{
+				objs.NodeRoleBinding, objs.ControllerRoleBinding, objs.ProvisionerRoleBinding, objs.RBACProxyRoleBinding,
+			}),
+			// The finalizer is kept until everything is removed
+			expectedFinalizers: []string{testFinalizer, "other"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("OPERATOR_NAME", testOperatorName)
+			now := metav1.Now()
+			meta := &metav1.ObjectMeta{
+				Name:              "efs.csi.aws.com",
+				DeletionTimestamp: &now,
+				Finalizers:        []string{testFinalizer, "other"},
+			}
+			c := newTestContext(objs, opv1.Managed, meta, test.existing...)
+			for _, f := range test.failOn {
+				failOn(c.kubeClient, f[0], f[1])
+			}
+			// Status of the applied objects and a generation of another controller
+			c.setStatus(t, func(status *opv1.OperatorStatus) {
+				status.Generations = []opv1.GenerationStatus{
+					{Group: "apps", Resource: "deployments", Namespace: testNamespace, Name: "controller", LastGeneration: 2},
+				}
+				for _, obj := range all {
+					status.Generations = append(status.Generations, generationStatus(obj))
+					operatorv1helpers.SetOperatorCondition(&status.Conditions, opv1.OperatorCondition{
+						Type:   objectConditionType(obj),
+						Status: opv1.ConditionFalse,
+					})
+				}
+			})
+
+			err := c.sync()
+			checkError(t, err, test.expectedErr)
+
+			// Only status of the objects that were not removed is kept
+			var expectedGenerations, expectedConditions []string
+			for _, obj := range all {
+				if key := objectKeys([]runtime.Object{obj})[0]; slices.Contains(test.expectedRemaining, key) {
+					expectedGenerations = append(expectedGenerations, key)
+					expectedConditions = append(expectedConditions, objectConditionType(obj))
+				}
+			}
+			expectedGenerations = append(expectedGenerations, "deployments/controller")
+			sort.Strings(expectedGenerations)
+			sort.Strings(expectedConditions)
+			if generations, conditions := c.objectStatus(t); !reflect.DeepEqual(generations, expectedGenerations) || !reflect.DeepEqual(conditions, expectedConditions) {
+				t.Errorf("expected generations %v and conditions %v, got %v and %v", expectedGenerations, expectedConditions, generations, conditions)
+			}
+
+			// Delete is called for every object, even for the missing ones.
+			// The StorageClass is deleted only when it was applied by the
+			// operator.
+			expectedDeleted := all
+			if test.expectedDeletedStorageClass {
+				expectedDeleted = append(expectedDeleted, storageClass)
+			}
+			if deleted, expected := actionKeys(c.kubeClient.Actions(), "delete"), objectKeys(expectedDeleted); !reflect.DeepEqual(deleted, expected) {
+				t.Errorf("expected deleted objects %v, got %v", expected, deleted)
+			}
+			if created := actionKeys(c.kubeClient.Actions(), "create"); len(created) > 0 {
+				t.Errorf("expected no created objects, got %v", created)
+			}
+			if remaining := existingKeys(c.kubeClient, append(all, storageClass)); !reflect.DeepEqual(remaining, test.expectedRemaining) {
+				t.Errorf("expected remaining objects %v, got %v", test.expectedRemaining, remaining)
+			}
+			if !reflect.DeepEqual(c.finalizers(), test.expectedFinalizers) {
+				t.Errorf("expected finalizers %v, got %v", test.expectedFinalizers, c.finalizers())
+			}
+		})
+	}
+}
+
+// exists checks the object in the tracker of the fake client, bypassing reactors.
+func exists(client *fake.Clientset, obj runtime.Object) bool {
+	accessor, err := metaAccessor(obj)
+	if err != nil {
+		panic(err)
+	}
+	_, err = client.Tracker().Get(resourceFor(obj), accessor.GetNamespace(), accessor.GetName())
+	return err == nil
+}
+
+// existingKeys returns sorted <resource>/<name> of the objects that exist in the fake client.
+func existingKeys(client *fake.Clientset, objs []runtime.Object) []string {
+	var keys []string
+	for _, obj := range objs {
+		if exists(client, obj) {
+			keys = append(keys, objectKeys([]runtime.Object{obj})...)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestReadAssets(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         []string
+		expectedTypes []string
+		expectedErr   string
+	}{
+		{
+			name:          "typed objects in the order of files",
+			files:         []string{"service.yaml", "csidriver.yaml", "networkpolicy.yaml"},
+			expectedTypes: []string{"*v1.Service", "*v1.CSIDriver", "*v1.NetworkPolicy"},
+		},
+		{
+			name:        "missing asset",
+			files:       []string{"csidriver.yaml", "missing.yaml"},
+			expectedErr: `missing "missing.yaml"`,
+		},
+		{
+			name:        "invalid asset",
+			files:       []string{"invalid.yaml"},
+			expectedErr: `cannot decode "invalid.yaml"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs, err := readAssets(testAssetFunc, test.files)
+			checkError(t, err, test.expectedErr)
+
+			var types []string
+			for _, obj := range objs {
+				types = append(types, fmt.Sprintf("%T", obj))
+			}
+			if !reflect.DeepEqual(types, test.expectedTypes) {
+				t.Errorf("expected types %v, got %v", test.expectedTypes, types)
+			}
+		})
+	}
+}
+
+func TestSyncOrder(t *testing.T) {
+	all := allObjects(newTestObjects())
+	// Objects are applied in the order of the asset files and removed in the reverse order
+	applyOrder := orderedObjectKeys(all)
+	var removeOrder []string
+	for i := len(all) - 1; i >= 0; i-- {
+		removeOrder = append(removeOrder, orderedObjectKeys(all[i:i+1])...)
+	}
+
+	t.Setenv("OPERATOR_NAME", testOperatorName)
+	c := newTestContext(newTestObjects(), opv1.Managed, nil)
+	if err := c.sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created := orderedActionKeys(c.kubeClient.Actions(), "create"); !reflect.DeepEqual(created, applyOrder) {
+		t.Errorf("expected objects created in order %v, got %v", applyOrder, created)
+	}
+
+	now := metav1.Now()
+	meta := &metav1.ObjectMeta{
+		Name:              "efs.csi.aws.com",
+		DeletionTimestamp: &now,
+		Finalizers:        []string{testFinalizer},
+	}
+	c = newTestContext(newTestObjects(), opv1.Managed, meta, all...)
+	if err := c.sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted := orderedActionKeys(c.kubeClient.Actions(), "delete"); !reflect.DeepEqual(deleted, removeOrder) {
+		t.Errorf("expected objects deleted in order %v, got %v", removeOrder, deleted)
+	}
+}
+
+// ownedObject returns a RoleBinding labeled as applied by the owner in the version.
+func ownedObject(name, owner, version string) *rbacv1.RoleBinding {
+	binding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace}}
+	if owner != "" {
+		binding.Labels = map[string]string{ownerLabel: owner, versionLabel: version}
+	}
+	return binding
+}
+
+func TestSyncOrphans(t *testing.T) {
+	objs := newTestObjects()
+	applied := allObjects(objs)
+
+	tests := []struct {
+		name            string
+		managementState opv1.ManagementState
+		existing        []runtime.Object
+		failOn          [][]string
+		// expected results
+		expectedErr     string
+		expectedDeleted []string
+		// expectedApplied checks the owner labels of the applied objects
+		expectedApplied bool
+	}{
+		{
+			name:            "orphaned objects",
+			managementState: opv1.Managed,
+			existing: []runtime.Object{
+				ownedObject("old-binding", ownerLabelValue, "4.15.0"),
+				&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{
+					Name:   "old-role",
+					Labels: map[string]string{ownerLabel: ownerLabelValue},
+				}},
+				// Not owned by the controller
+				ownedObject("user-binding", "", ""),
+				ownedObject("other-binding", "OtherController", "4.15.0"),
+			},
+			expectedDeleted: []string{"clusterroles/old-role", "rolebindings/old-binding"},
+			expectedApplied: true,
+		},
+		{
+			name:            "orphan delete error",
+			managementState: opv1.Managed,
+			existing: []runtime.Object{
+				ownedObject("old-binding", ownerLabelValue, "4.15.0"),
+			},
+			failOn:          [][]string{{"delete", "rolebindings"}},
+			expectedErr:     "injected delete rolebindings error",
+			expectedDeleted: []string{"rolebindings/old-binding"},
+			expectedApplied: true,
+		},
+		{
+			name:            "unmanaged",
+			managementState: opv1.Unmanaged,
+			existing: []runtime.Object{
+				ownedObject("old-binding", ownerLabelValue, "4.15.0"),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("OPERATOR_NAME", testOperatorName)
+			c := newTestContext(objs, test.managementState, nil, test.existing...)
+			for _, f := range test.failOn {
+				failOn(c.kubeClient, f[0], f[1])
+			}
+
+			err := c.sync()
+			checkError(t, err, test.expectedErr)
+
+			deleted := actionKeys(c.kubeClient.Actions(), "delete")
+			if !reflect.DeepEqual(deleted, test.expectedDeleted) {
+				t.Errorf("expected deleted objects %v, got %v", test.expectedDeleted, deleted)
+			}
+			if !test.expectedApplied {
+				return
+			}
+			// Applied objects are labeled as owned by the controller
+			if existing := existingKeys(c.kubeClient, applied); !reflect.DeepEqual(existing, objectKeys(applied)) {
+				t.Errorf("expected existing objects %v, got %v", objectKeys(applied), existing)
+			}
+			for _, obj := range applied {
+				accessor, _ := metaAccessor(obj)
+				live, err := c.kubeClient.Tracker().Get(resourceFor(obj), accessor.GetNamespace(), accessor.GetName())
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				objLabels := live.(metav1.Object).GetLabels()
+				if objLabels[ownerLabel] != ownerLabelValue || objLabels[versionLabel] != testVersion {
+					t.Errorf("expected %s to be labeled as owned by %s version %s, got labels %v",
+						objectKeys([]runtime.Object{obj})[0], ownerLabelValue, testVersion, objLabels)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncNetworkPolicy(t *testing.T) {
+	t.Setenv("OPERATOR_NAME", testOperatorName)
+	policy := mustReadAssets(testAssetFunc, []string{"networkpolicy.yaml"})[0]
+
+	// NetworkPolicies are shipped by adding their asset file
+	c := newTestContext(newTestObjects(), opv1.Managed, nil)
+	c.controller.objs = append(c.controller.objs, policy)
+	if err := c.sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !exists(c.kubeClient, policy) {
+		t.Errorf("expected %s to be created", objectKeys([]runtime.Object{policy})[0])
+	}
+
+	now := metav1.Now()
+	meta := &metav1.ObjectMeta{
+		Name:              "efs.csi.aws.com",
+		DeletionTimestamp: &now,
+		Finalizers:        []string{testFinalizer},
+	}
+	c = newTestContext(newTestObjects(), opv1.Managed, meta, policy)
+	c.controller.objs = append(c.controller.objs, policy)
+	if err := c.sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists(c.kubeClient, policy) {
+		t.Errorf("expected %s to be removed", objectKeys([]runtime.Object{policy})[0])
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	tests := []struct {
+		name           string
+		live           runtime.Object
+		applied        runtime.Object
+		expectedFields []string
+	}{
+		{
+			name: "no change",
+			live: &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "role", ResourceVersion: "1"},
+				Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}}},
+			},
+			applied: &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "role", ResourceVersion: "2"},
+				Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}}},
+			},
+		},
+		{
+			name: "ownership labels are ignored",
+			live: &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "role", Labels: map[string]string{versionLabel: "4.15.0"}},
+			},
+			applied: &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "role", Labels: map[string]string{ownerLabel: ownerLabelValue, versionLabel: testVersion}},
+			},
+		},
+		{
+			name: "changed rules",
+			live: &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "role"},
+				Rules:      []rbacv1.PolicyRule{{Verbs: []string{"*"}}},
+			},
+			applied: &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "role"},
+				Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}}},
+			},
+			expectedFields: []string{"rules"},
+		},
+		{
+			name: "nested fields and annotations",
+			live: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "service", Annotations: map[string]string{"foo": "bar"}},
+				Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "other"}, Type: corev1.ServiceTypeNodePort},
+			},
+			applied: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "service"},
+				Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "driver"}, Type: corev1.ServiceTypeClusterIP},
+			},
+			expectedFields: []string{"metadata.annotations", "spec.selector.app", "spec.type"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields, err := changedFields(test.live, test.applied)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(fields, test.expectedFields) {
+				t.Errorf("expected changed fields %v, got %v", test.expectedFields, fields)
+			}
+		})
+	}
+}
+
+func TestSyncDrift(t *testing.T) {
+	t.Setenv("OPERATOR_NAME", testOperatorName)
+	c := newTestContext(newTestObjects(), opv1.Managed, nil)
+	recorder := c.controller.eventRecorder.(events.InMemoryRecorder)
+
+	// checkSync syncs and checks the revert events and the drifting condition.
+	checkSync := func(expectedReverts int, expectedDrifting opv1.ConditionStatus) {
+		t.Helper()
+		before := len(revertEvents(recorder))
+		if err := c.sync(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		reverts := revertEvents(recorder)[before:]
+		if len(reverts) != expectedReverts {
+			t.Errorf("expected %d revert events, got %d", expectedReverts, len(reverts))
+		}
+		for _, event := range reverts {
+			if !strings.Contains(event.Message, "ClusterRole.rbac.authorization.k8s.io/privileged-role: rules") {
+				t.Errorf("expected revert of privileged-role rules, got %q", event.Message)
+			}
+		}
+		_, status, _, err := c.operatorClient.GetOperatorState()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cond := operatorv1helpers.FindOperatorCondition(status.Conditions, driftingCondition)
+		if cond == nil || cond.Status != expectedDrifting {
+			t.Errorf("expected %s condition %s, got %+v", driftingCondition, expectedDrifting, cond)
+		}
+	}
+	// changeRole changes the role as someone else than the controller.
+	changeRole := func() {
+		t.Helper()
+		obj, err := c.kubeClient.Tracker().Get(rbacv1.SchemeGroupVersion.WithResource("clusterroles"), "", "privileged-role")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		role := obj.(*rbacv1.ClusterRole)
+		role.Rules = []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}}
+		if err := c.kubeClient.Tracker().Update(rbacv1.SchemeGroupVersion.WithResource("clusterroles"), role, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Creating the objects is not a revert
+	checkSync(0, opv1.ConditionFalse)
+	// Nothing changed
+	checkSync(0, opv1.ConditionFalse)
+
+	for i := 1; i <= driftThreshold; i++ {
+		changeRole()
+		c.clock.SetTime(c.clock.Now().Add(time.Minute))
+		expectedDrifting := opv1.ConditionFalse
+		if i == driftThreshold {
+			expectedDrifting = opv1.ConditionTrue
+		}
+		checkSync(1, expectedDrifting)
+	}
+
+	// The reverts are forgotten after the window
+	c.clock.SetTime(c.clock.Now().Add(driftWindow))
+	checkSync(0, opv1.ConditionFalse)
+}
+
+func revertEvents(recorder events.InMemoryRecorder) []*corev1.Event {
+	var ret []*corev1.Event
+	for _, event := range recorder.Events() {
+		if event.Reason == "StaticResourceReverted" {
+			ret = append(ret, event)
+		}
+	}
+	return ret
+}
+
+// errorReactor makes the fake client return its err for the verb on the
+// resource. It's registered once, tests switch it by setting err.
+type errorReactor struct {
+	err error
+}
+
+func addErrorReactor(client *fake.Clientset, verb, resource string) *errorReactor {
+	reactor := &errorReactor{}
+	client.PrependReactor(verb, resource, func(action core.Action) (bool, runtime.Object, error) {
+		if reactor.err == nil {
+			return false, nil, nil
+		}
+		return true, nil, reactor.err
+	})
+	return reactor
+}
+
+func TestSyncObjectStatus(t *testing.T) {
+	t.Setenv("OPERATOR_NAME", testOperatorName)
+	// CSIDriver is one of the few kinds with metadata.generation
+	existingDriver := &storagev1.CSIDriver{ObjectMeta: metav1.ObjectMeta{Name: "efs.csi.aws.com", Generation: 5}}
+	orphan := ownedObject("old-binding", ownerLabelValue, "4.15.0")
+	c := newTestContext(newTestObjects(), opv1.Managed, nil, existingDriver, orphan)
+	createServices := addErrorReactor(c.kubeClient, "create", "services")
+	updateDrivers := addErrorReactor(c.kubeClient, "update", "csidrivers")
+
+	// Generations from a previous version and from other controllers
+	_, status, resourceVersion, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status.Generations = []opv1.GenerationStatus{
+		{Group: "rbac.authorization.k8s.io", Resource: "rolebindings", Namespace: testNamespace, Name: "old-binding", LastGeneration: 1},
+		{Group: "apps", Resource: "deployments", Namespace: testNamespace, Name: "controller", LastGeneration: 2},
+	}
+	if _, err := c.operatorClient.UpdateOperatorStatus(context.TODO(), resourceVersion, status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// checkStatus checks generations of the objects as <resource>/<name>=<generation>/<hash>
+	// and the StaticResourcesFailing condition.
+	checkStatus := func(expectedGenerations map[string]string, expectedFailing opv1.ConditionStatus, expectedMessage string) {
+		t.Helper()
+		_, status, _, err := c.operatorClient.GetOperatorState()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		generations := map[string]string{}
+		for _, generation := range status.Generations {
+			generations[generation.Resource+"/"+generation.Name] = fmt.Sprintf("%d/%s", generation.LastGeneration, generation.Hash)
+		}
+		if !reflect.DeepEqual(generations, expectedGenerations) {
+			t.Errorf("expected generations %v, got %v", expectedGenerations, generations)
+		}
+		cond := operatorv1helpers.FindOperatorCondition(status.Conditions, failingCondition)
+		if cond == nil || cond.Status != expectedFailing || !strings.Contains(cond.Message, expectedMessage) {
+			t.Errorf("expected %s condition %s with message %q, got %+v", failingCondition, expectedFailing, expectedMessage, cond)
+		}
+	}
+	// checkObjectCondition checks the per-object condition of the object.
+	checkObjectCondition := func(obj runtime.Object, expectedStatus opv1.ConditionStatus, expectedReason, expectedMessage string) {
+		t.Helper()
+		_, status, _, err := c.operatorClient.GetOperatorState()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		conditionType := objectConditionType(obj)
+		cond := operatorv1helpers.FindOperatorCondition(status.Conditions, conditionType)
+		if cond == nil || cond.Status != expectedStatus || cond.Reason != expectedReason || !strings.Contains(cond.Message, expectedMessage) {
+			t.Errorf("expected %s condition %s with reason %s and message %q, got %+v", conditionType, expectedStatus, expectedReason, expectedMessage, cond)
+		}
+	}
+	// setDriverGeneration changes the driver as someone else than the controller.
+	setDriverGeneration := func(generation int64) {
+		t.Helper()
+		obj, err := c.kubeClient.Tracker().Get(storagev1.SchemeGroupVersion.WithResource("csidrivers"), "", existingDriver.Name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		driver := obj.(*storagev1.CSIDriver).DeepCopy()
+		driver.Generation = generation
+		driver.Labels = nil
+		if err := c.kubeClient.Tracker().Update(storagev1.SchemeGroupVersion.WithResource("csidrivers"), driver, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Objects without metadata.generation have only the hash of the applied manifest
+	expectedGenerations := map[string]string{}
+	for _, obj := range c.controller.objs {
+		hash, err := objectHash(c.controller.withOwnerLabels(obj))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectedGenerations[objectKeys([]runtime.Object{obj})[0]] = "0/" + hash
+	}
+	driverHash := strings.TrimPrefix(expectedGenerations["csidrivers/efs.csi.aws.com"], "0/")
+	expectedGenerations["csidrivers/efs.csi.aws.com"] = "5/" + driverHash
+	// Objects that were never applied have an entry without a hash
+	expectedGenerations["services/controller-metrics"] = "0/"
+	expectedGenerations["services/operator-metrics"] = "0/"
+	// Generations of other controllers are kept, the ones of orphans are removed
+	expectedGenerations["deployments/controller"] = "2/"
+
+	// Errors without an API reason are reported as ApplyFailed
+	createServices.err = fmt.Errorf("injected create services error")
+	if err := c.sync(); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	checkStatus(expectedGenerations, opv1.ConditionTrue, "controller-metrics -n test-namespace: ApplyFailed: injected create services error")
+	if conditionType := objectConditionType(newTestObjects().MetricsService); conditionType != "StaticResourceFailing_Service_controller-metrics" {
+		t.Errorf("unexpected condition type %s", conditionType)
+	}
+	checkObjectCondition(newTestObjects().MetricsService, opv1.ConditionTrue, "ApplyFailed", "injected create services error")
+	checkObjectCondition(newTestObjects().CSIDriver, opv1.ConditionFalse, "AsExpected", "")
+
+	// Objects keep the last applied generation when apply fails
+	createServices.err = nil
+	for _, obj := range []runtime.Object{newTestObjects().MetricsService, newTestObjects().OperatorMetricsService} {
+		hash, err := objectHash(c.controller.withOwnerLabels(obj))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectedGenerations[objectKeys([]runtime.Object{obj})[0]] = "0/" + hash
+	}
+	setDriverGeneration(6)
+	updateDrivers.err = apierrors.NewForbidden(storagev1.Resource("csidrivers"), existingDriver.Name, fmt.Errorf("injected"))
+	if err := c.sync(); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	checkStatus(expectedGenerations, opv1.ConditionTrue, "efs.csi.aws.com: Forbidden: ")
+	checkObjectCondition(newTestObjects().CSIDriver, opv1.ConditionTrue, "Forbidden", "injected")
+	checkObjectCondition(newTestObjects().MetricsService, opv1.ConditionFalse, "AsExpected", "")
+
+	updateDrivers.err = nil
+	if err := c.sync(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectedGenerations["csidrivers/efs.csi.aws.com"] = "6/" + driverHash
+	checkStatus(expectedGenerations, opv1.ConditionFalse, "")
+	checkObjectCondition(newTestObjects().CSIDriver, opv1.ConditionFalse, "AsExpected", "")
+}
@@ -0,0 +1,83 @@
+package staticresource
+
+import (
+	"context"
+
+	"github.com/openshift/library-go/pkg/operator/resource/resourcehelper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ownerLabel marks objects applied by the controller, its value is ownerLabelValue.
+	ownerLabel = "aws-efs-csi-driver-operator.openshift.io/owner"
+	// ownerLabelValue must not change between operator versions, a newer
+	// version would not find orphans labeled by the older one.
+	ownerLabelValue = "aws-efs-csi-driver-operator"
+	// versionLabel is the version of the operator that applied the object.
+	versionLabel = "aws-efs-csi-driver-operator.openshift.io/version"
+)
+
+// withOwnerLabels returns a copy of the object labeled as owned by the controller.
+func (c *CSIStaticResourceController) withOwnerLabels(obj runtime.Object) runtime.Object {
+	obj = obj.DeepCopyObject()
+	accessor := obj.(metav1.Object)
+	objLabels := accessor.GetLabels()
+	if objLabels == nil {
+		objLabels = map[string]string{}
+	}
+	objLabels[ownerLabel] = ownerLabelValue
+	objLabels[versionLabel] = c.version
+	accessor.SetLabels(objLabels)
+	return obj
+}
+
+// deleteOrphans removes objects owned by the controller that are not in the
+// current assets anymore, e.g. when a newer version of the operator removed or
+// renamed an asset. Namespaced orphans are searched only in the operator
+// namespace, the only one with informers. It returns the removed objects.
+func (c *CSIStaticResourceController) deleteOrphans(ctx context.Context) ([]runtime.Object, error) {
+	current := sets.New[string]()
+	for _, obj := range c.objs {
+		current.Insert(objectKey(obj))
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{ownerLabel: ownerLabelValue})
+	owned, err := c.listers.list(c.operatorNamespace, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []runtime.Object
+	var errs []error
+	for _, obj := range owned {
+		if current.Has(objectKey(obj)) {
+			continue
+		}
+		klog.Infof("Deleting %s applied by operator version %q, it is not in the current assets",
+			resourcehelper.FormatResourceForCLIWithNamespace(obj), obj.(metav1.Object).GetLabels()[versionLabel])
+		err := deleteObject(ctx, c.kubeClient, obj)
+		if apierrors.IsNotFound(err) {
+			removed = append(removed, obj)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, obj)
+		c.eventRecorder.Eventf("OrphanDeleted", "Deleted %s, it is not in the current assets", resourcehelper.FormatResourceForCLIWithNamespace(obj))
+	}
+	return removed, errors.NewAggregate(errs)
+}
+
+// objectKey identifies the object among all kinds handled by the controller.
+func objectKey(obj runtime.Object) string {
+	accessor := obj.(metav1.Object)
+	return resourcehelper.GuessObjectGroupVersionKind(obj).GroupKind().String() + "/" + accessor.GetNamespace() + "/" + accessor.GetName()
+}
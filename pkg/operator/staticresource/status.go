@@ -0,0 +1,157 @@
+package staticresource
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/resource/resourcehelper"
+	"github.com/openshift/library-go/pkg/operator/resource/resourcemerge"
+	operatorv1helpers "github.com/openshift/library-go/pkg/operator/v1helpers"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	failingCondition = "StaticResourcesFailing"
+	// objectFailingConditionPrefix is the prefix of per-object conditions,
+	// see objectConditionType
+	objectFailingConditionPrefix = "StaticResourceFailing"
+)
+
+// applyResult is the result of applying one object.
+type applyResult struct {
+	required runtime.Object
+	// applied is the object returned by the API server, nil on error
+	applied runtime.Object
+	err     error
+}
+
+// generationStatus returns the generations entry of the object, with an empty
+// LastGeneration and Hash.
+func generationStatus(obj runtime.Object) opv1.GenerationStatus {
+	gvk := resourcehelper.GuessObjectGroupVersionKind(obj)
+	resource, _ := meta.UnsafeGuessKindToResource(gvk)
+	accessor := obj.(metav1.Object)
+	return opv1.GenerationStatus{
+		Group:     gvk.Group,
+		Resource:  resource.Resource,
+		Namespace: accessor.GetNamespace(),
+		Name:      accessor.GetName(),
+	}
+}
+
+// objectConditionType returns the type of the condition that reports whether
+// the object failed to apply, StaticResourceFailing_<kind>_<name>.
+func objectConditionType(obj runtime.Object) string {
+	gvk := resourcehelper.GuessObjectGroupVersionKind(obj)
+	return fmt.Sprintf("%s_%s_%s", objectFailingConditionPrefix, gvk.Kind, obj.(metav1.Object).GetName())
+}
+
+// objectHash returns a hash of the object as applied by the controller.
+func objectHash(obj runtime.Object) (string, error) {
+	hasher := fnv.New32()
+	if err := json.NewEncoder(hasher).Encode(obj); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// failureReason returns the reason of the API error, or ApplyFailed for other
+// errors.
+func failureReason(err error) string {
+	if reason := apierrors.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+		return string(reason)
+	}
+	return "ApplyFailed"
+}
+
+// updateObjectStatusFn records each applied object in status.generations and
+// the errors of objects that failed to apply in the StaticResourcesFailing
+// condition and in the condition of each object. Generations and conditions of
+// removed objects are dropped.
+// Hash is the hash of the last applied manifest. Most kinds, such as
+// ServiceAccounts, ConfigMaps, RBAC objects and Services, have no
+// metadata.generation, LastGeneration is set only for kinds that have it.
+func updateObjectStatusFn(results []applyResult, removed []runtime.Object) operatorv1helpers.UpdateStatusFunc {
+	return func(status *opv1.OperatorStatus) error {
+		var failures []string
+		for _, result := range results {
+			generation := generationStatus(result.required)
+			objectCondition := opv1.OperatorCondition{
+				Type:   objectConditionType(result.required),
+				Status: opv1.ConditionFalse,
+				Reason: "AsExpected",
+			}
+			if result.err != nil {
+				objectCondition.Status = opv1.ConditionTrue
+				objectCondition.Reason = failureReason(result.err)
+				objectCondition.Message = result.err.Error()
+			}
+			operatorv1helpers.SetOperatorCondition(&status.Conditions, objectCondition)
+
+			if result.err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %s: %v",
+					resourcehelper.FormatResourceForCLIWithNamespace(result.required), failureReason(result.err), result.err))
+				resource := schema.GroupResource{Group: generation.Group, Resource: generation.Resource}
+				if resourcemerge.GenerationFor(status.Generations, resource, generation.Namespace, generation.Name) != nil {
+					// Keep the entry of the last successful apply
+					continue
+				}
+			} else {
+				hash, err := objectHash(result.required)
+				if err != nil {
+					return err
+				}
+				generation.LastGeneration = result.applied.(metav1.Object).GetGeneration()
+				generation.Hash = hash
+			}
+			resourcemerge.SetGeneration(&status.Generations, generation)
+		}
+
+		removeObjectStatus(status, removed)
+
+		condition := opv1.OperatorCondition{
+			Type:   failingCondition,
+			Status: opv1.ConditionFalse,
+			Reason: "AsExpected",
+		}
+		if len(failures) > 0 {
+			condition.Status = opv1.ConditionTrue
+			condition.Reason = "ApplyFailed"
+			condition.Message = strings.Join(failures, "\n")
+		}
+		operatorv1helpers.SetOperatorCondition(&status.Conditions, condition)
+		return nil
+	}
+}
+
+// removeObjectStatusFn drops generations and conditions of the removed objects.
+func removeObjectStatusFn(removed []runtime.Object) operatorv1helpers.UpdateStatusFunc {
+	return func(status *opv1.OperatorStatus) error {
+		removeObjectStatus(status, removed)
+		return nil
+	}
+}
+
+func removeObjectStatus(status *opv1.OperatorStatus, removed []runtime.Object) {
+	for _, obj := range removed {
+		removedGeneration := generationStatus(obj)
+		var generations []opv1.GenerationStatus
+		for _, generation := range status.Generations {
+			key := generation
+			key.LastGeneration, key.Hash = 0, ""
+			if key != removedGeneration {
+				generations = append(generations, generation)
+			}
+		}
+		status.Generations = generations
+		operatorv1helpers.RemoveOperatorCondition(&status.Conditions, objectConditionType(obj))
+	}
+}
@@ -0,0 +1,260 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	opinformers "github.com/openshift/client-go/operator/informers/externalversions"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	storagev1informers "k8s.io/client-go/informers/storage/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	storagev1listers "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/aws-efs-csi-driver-operator/assets"
+)
+
+const (
+	storageClassAsset = "storageclass.yaml"
+	// storageClassOwnerLabel marks the StorageClass applied by the operator,
+	// see storageclass.yaml. A StorageClass without it is never updated nor
+	// deleted.
+	storageClassOwnerLabel = "aws-efs-csi-driver-operator.openshift.io/managed-storageclass"
+)
+
+var (
+	fileSystemIDRegexp   = regexp.MustCompile(`^fs-[0-9a-f]+$`)
+	directoryPermsRegexp = regexp.MustCompile(`^[0-7]{3,4}$`)
+)
+
+// storageClassController applies the efs-sc StorageClass for the file system
+// configured in the driver ConfigMap. The StorageClass is deleted when the file
+// system ID is removed from the ConfigMap, CSIStaticResourceController deletes
+// it when the operator is removed. StorageClassState of the ClusterCSIDriver is respected: the
+// StorageClass is not reconciled when Unmanaged and deleted when Removed.
+// When the cross-account Secret exists, it's passed to the driver as the
+// provisioner secret of the StorageClass. A malformed Secret is reported in
+// the CrossAccountSecretDegraded condition and not passed to the driver.
+type storageClassController struct {
+	namespace          string
+	configMapName      string
+	secretName         string
+	operatorClient     v1helpers.OperatorClient
+	kubeClient         kubernetes.Interface
+	configMapLister    corev1listers.ConfigMapLister
+	secretLister       corev1listers.SecretLister
+	storageClassLister storagev1listers.StorageClassLister
+	scStateEvaluator   *csistorageclasscontroller.StorageClassStateEvaluator
+	eventRecorder      events.Recorder
+}
+
+func newStorageClassController(
+	name string,
+	namespace string,
+	configMapName string,
+	secretName string,
+	operatorClient v1helpers.OperatorClient,
+	kubeClient kubernetes.Interface,
+	configMapInformer corev1informers.ConfigMapInformer,
+	secretInformer corev1informers.SecretInformer,
+	storageClassInformer storagev1informers.StorageClassInformer,
+	operatorInformer opinformers.SharedInformerFactory,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &storageClassController{
+		namespace:          namespace,
+		configMapName:      configMapName,
+		secretName:         secretName,
+		operatorClient:     operatorClient,
+		kubeClient:         kubeClient,
+		configMapLister:    configMapInformer.Lister(),
+		secretLister:       secretInformer.Lister(),
+		storageClassLister: storageClassInformer.Lister(),
+		scStateEvaluator: csistorageclasscontroller.NewStorageClassStateEvaluator(
+			kubeClient,
+			operatorInformer.Operator().V1().ClusterCSIDrivers().Lister(),
+			recorder,
+		),
+		eventRecorder: recorder,
+	}
+	return factory.New().
+		WithSyncDegradedOnError(operatorClient).
+		WithInformers(
+			operatorClient.Informer(),
+			configMapInformer.Informer(),
+			secretInformer.Informer(),
+			storageClassInformer.Informer(),
+			operatorInformer.Operator().V1().ClusterCSIDrivers().Informer(),
+		).
+		WithSync(c.sync).
+		ResyncEvery(time.Minute).
+		ToController(name, recorder.WithComponentSuffix("storageclass-controller"))
+}
+
+func (c *storageClassController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	secret, err := c.secretLister.Secrets(c.namespace).Get(c.secretName)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	// A malformed Secret is only reported, the StorageClass is synced without
+	// the cross-account parameters until the Secret is fixed
+	crossAccountParameters, secretErr := getCrossAccountParameters(secret)
+	if _, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, crossAccountSecretConditionFn(secretErr)); err != nil {
+		return err
+	}
+
+	cm, err := c.configMapLister.ConfigMaps(c.namespace).Get(c.configMapName)
+	if errors.IsNotFound(err) {
+		return c.deleteStorageClass(ctx, fmt.Sprintf("ConfigMap %s/%s does not exist", c.namespace, c.configMapName))
+	}
+	if err != nil {
+		return err
+	}
+	config, err := parseDriverConfig(cm)
+	if err != nil {
+		return fmt.Errorf("invalid ConfigMap %s/%s: %v", c.namespace, c.configMapName, err)
+	}
+	if len(config.storageClassParameters) == 0 {
+		return c.deleteStorageClass(ctx, fmt.Sprintf("ConfigMap %s/%s has no %s", c.namespace, c.configMapName, fileSystemIDKey))
+	}
+
+	sc, err := getStorageClass(config.storageClassParameters, crossAccountParameters)
+	if err != nil {
+		return err
+	}
+	existing, err := c.storageClassLister.Get(sc.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && !isOwnedStorageClass(existing) {
+		return fmt.Errorf("StorageClass %s already exists and is not managed by the operator, remove it or the %s label", sc.Name, storageClassOwnerLabel)
+	}
+	return c.scStateEvaluator.EvalAndApplyStorageClass(ctx, sc)
+}
+
+// deleteStorageClass deletes the StorageClass applied by the operator. A
+// StorageClass without the owner label and an Unmanaged StorageClass are kept.
+func (c *storageClassController) deleteStorageClass(ctx context.Context, reason string) error {
+	sc, err := getStorageClass()
+	if err != nil {
+		return err
+	}
+	existing, err := c.storageClassLister.Get(sc.Name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !isOwnedStorageClass(existing) {
+		klog.V(4).Infof("StorageClass %s is not managed by the operator, not deleting it", sc.Name)
+		return nil
+	}
+	if c.scStateEvaluator.GetStorageClassState(sc.Provisioner) == opv1.UnmanagedStorageClass {
+		klog.V(4).Infof("StorageClass %s is Unmanaged, not deleting it", sc.Name)
+		return nil
+	}
+	err = c.kubeClient.StorageV1().StorageClasses().Delete(ctx, sc.Name, metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.eventRecorder.Eventf("StorageClassDeleted", "Deleted StorageClass %s: %s", sc.Name, reason)
+	return nil
+}
+
+func isOwnedStorageClass(sc *storagev1.StorageClass) bool {
+	return sc.Labels[storageClassOwnerLabel] == "true"
+}
+
+// getStorageClass returns the StorageClass from the asset with the given
+// parameters, later ones take precedence.
+func getStorageClass(parameters ...map[string]string) (*storagev1.StorageClass, error) {
+	content, err := assets.ReadFile(storageClassAsset)
+	if err != nil {
+		return nil, err
+	}
+	sc := resourceread.ReadStorageClassV1OrDie(content)
+	for _, params := range parameters {
+		for key, value := range params {
+			sc.Parameters[key] = value
+		}
+	}
+	return sc, nil
+}
+
+func validateStorageClassParameter(key, value string) error {
+	switch key {
+	case fileSystemIDKey:
+		if !fileSystemIDRegexp.MatchString(value) {
+			return fmt.Errorf("%s must be an EFS file system ID such as fs-0123456789abcdef0, got %q", key, value)
+		}
+	case basePathKey:
+		if !strings.HasPrefix(value, "/") {
+			return fmt.Errorf("%s must be an absolute path, got %q", key, value)
+		}
+	case directoryPermsKey:
+		if !directoryPermsRegexp.MatchString(value) {
+			return fmt.Errorf("%s must be an octal mode such as 700, got %q", key, value)
+		}
+	case uidKey, gidKey, gidRangeStartKey, gidRangeEndKey:
+		if id, err := strconv.ParseInt(value, 10, 64); err != nil || id < 0 {
+			return fmt.Errorf("%s must be a non-negative number, got %q", key, value)
+		}
+	}
+	return nil
+}
+
+// validateStorageClassParameters checks that the parameters are complete and
+// consistent. Individual values are checked by validateStorageClassParameter.
+func validateStorageClassParameters(parameters map[string]string) error {
+	if len(parameters) == 0 {
+		return nil
+	}
+	if _, found := parameters[fileSystemIDKey]; !found {
+		var keys []string
+		for key := range parameters {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("StorageClass parameters %v require %s", keys, fileSystemIDKey)
+	}
+	start, hasStart := parameters[gidRangeStartKey]
+	end, hasEnd := parameters[gidRangeEndKey]
+	if hasStart != hasEnd {
+		return fmt.Errorf("%s and %s must be set together", gidRangeStartKey, gidRangeEndKey)
+	}
+	if hasStart {
+		startID, _ := strconv.ParseInt(start, 10, 64)
+		endID, _ := strconv.ParseInt(end, 10, 64)
+		if startID > endID {
+			return fmt.Errorf("%s %d is greater than %s %d", gidRangeStartKey, startID, gidRangeEndKey, endID)
+		}
+	}
+	return nil
+}
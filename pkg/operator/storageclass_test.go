@@ -0,0 +1,373 @@
+package operator
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	oplisters "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	storagev1listers "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const testNamespace = "test-namespace"
+
+const testOperatorName = "test"
+
+type testStorageClassController struct {
+	*storageClassController
+	kubeClient     *fake.Clientset
+	operatorClient v1helpers.OperatorClient
+}
+
+// newTestStorageClassController returns the controller of a Managed operator
+// with listers that contain the given ConfigMaps, Secrets and StorageClasses.
+func newTestStorageClassController(objects ...runtime.Object) *testStorageClassController {
+	configMaps := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	storageClasses := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	var existing []runtime.Object
+	for _, obj := range objects {
+		switch obj.(type) {
+		case *corev1.ConfigMap:
+			configMaps.Add(obj)
+		case *corev1.Secret:
+			secrets.Add(obj)
+		case *storagev1.StorageClass:
+			storageClasses.Add(obj)
+			existing = append(existing, obj)
+		}
+	}
+	kubeClient := fake.NewSimpleClientset(existing...)
+	operatorClient := v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil)
+	recorder := events.NewInMemoryRecorder("test")
+	return &testStorageClassController{
+		storageClassController: &storageClassController{
+			namespace:          testNamespace,
+			configMapName:      driverConfigMapName,
+			secretName:         crossAccountSecretName,
+			operatorClient:     operatorClient,
+			kubeClient:         kubeClient,
+			configMapLister:    corev1listers.NewConfigMapLister(configMaps),
+			secretLister:       corev1listers.NewSecretLister(secrets),
+			storageClassLister: storagev1listers.NewStorageClassLister(storageClasses),
+			scStateEvaluator: csistorageclasscontroller.NewStorageClassStateEvaluator(
+				kubeClient,
+				oplisters.NewClusterCSIDriverLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+				recorder,
+			),
+			eventRecorder: recorder,
+		},
+		kubeClient:     kubeClient,
+		operatorClient: operatorClient,
+	}
+}
+
+func (c *testStorageClassController) sync(t *testing.T) {
+	t.Helper()
+	if err := c.syncErr(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func (c *testStorageClassController) syncErr() error {
+	return c.storageClassController.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test")))
+}
+
+// storageClasses returns names of the StorageClasses in the fake client.
+func (c *testStorageClassController) storageClasses(t *testing.T) []string {
+	t.Helper()
+	scs, err := c.kubeClient.StorageV1().StorageClasses().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, sc := range scs.Items {
+		names = append(names, sc.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *testStorageClassController) condition(t *testing.T, conditionType string) *opv1.OperatorCondition {
+	t.Helper()
+	_, status, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return v1helpers.FindOperatorCondition(status.Conditions, conditionType)
+}
+
+func newDriverConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: driverConfigMapName, Namespace: testNamespace},
+		Data:       data,
+	}
+}
+
+func TestStorageClassControllerCrossAccount(t *testing.T) {
+	config := newDriverConfigMap(map[string]string{fileSystemIDKey: "fs-0123456789abcdef0"})
+	tests := []struct {
+		name string
+		// nil when the StorageClass is not expected
+		expectedParameters map[string]string
+		expectedStatus     opv1.ConditionStatus
+		objects            []runtime.Object
+	}{
+		{
+			name:    "no secret",
+			objects: []runtime.Object{config},
+			expectedParameters: map[string]string{
+				fileSystemIDKey: "fs-0123456789abcdef0",
+			},
+			expectedStatus: opv1.ConditionFalse,
+		},
+		{
+			name:    "valid secret",
+			objects: []runtime.Object{config, newCrossAccountSecret(map[string]string{crossAccountRoleARNKey: testRoleARN})},
+			expectedParameters: map[string]string{
+				fileSystemIDKey:               "fs-0123456789abcdef0",
+				provisionerSecretNameKey:      crossAccountSecretName,
+				provisionerSecretNamespaceKey: testNamespace,
+			},
+			expectedStatus: opv1.ConditionFalse,
+		},
+		{
+			name:    "invalid secret",
+			objects: []runtime.Object{config, newCrossAccountSecret(map[string]string{crossAccountRoleARNKey: "admin"})},
+			// The StorageClass is synced without the Secret
+			expectedParameters: map[string]string{
+				fileSystemIDKey: "fs-0123456789abcdef0",
+			},
+			expectedStatus: opv1.ConditionTrue,
+		},
+		{
+			name: "invalid secret removes cross-account parameters",
+			objects: []runtime.Object{
+				newDriverConfigMap(map[string]string{fileSystemIDKey: "fs-0123456789abcdef0", basePathKey: "/data"}),
+				newCrossAccountSecret(map[string]string{crossAccountRoleARNKey: "admin"}),
+				newManagedStorageClass(map[string]string{
+					fileSystemIDKey:               "fs-0123456789abcdef0",
+					provisionerSecretNameKey:      crossAccountSecretName,
+					provisionerSecretNamespaceKey: testNamespace,
+				}),
+			},
+			expectedParameters: map[string]string{
+				fileSystemIDKey: "fs-0123456789abcdef0",
+				basePathKey:     "/data",
+			},
+			expectedStatus: opv1.ConditionTrue,
+		},
+		{
+			name:           "invalid secret without file system",
+			objects:        []runtime.Object{newCrossAccountSecret(nil)},
+			expectedStatus: opv1.ConditionTrue,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestStorageClassController(test.objects...)
+			c.sync(t)
+
+			condition := c.condition(t, crossAccountSecretCondition)
+			if condition == nil || condition.Status != test.expectedStatus {
+				t.Errorf("expected %s condition with status %s, got %+v", crossAccountSecretCondition, test.expectedStatus, condition)
+			}
+
+			scs, err := c.kubeClient.StorageV1().StorageClasses().List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedParameters == nil {
+				if len(scs.Items) != 0 {
+					t.Errorf("expected no StorageClass, got %d", len(scs.Items))
+				}
+				return
+			}
+			if len(scs.Items) != 1 {
+				t.Fatalf("expected one StorageClass, got %d", len(scs.Items))
+			}
+			for key, value := range test.expectedParameters {
+				if got := scs.Items[0].Parameters[key]; got != value {
+					t.Errorf("expected parameter %s=%q, got %q", key, value, got)
+				}
+			}
+			for _, key := range []string{provisionerSecretNameKey, provisionerSecretNamespaceKey} {
+				if _, found := test.expectedParameters[key]; !found {
+					if value, found := scs.Items[0].Parameters[key]; found {
+						t.Errorf("unexpected parameter %s=%q", key, value)
+					}
+				}
+			}
+		})
+	}
+}
+
+// newStorageClass returns a StorageClass of the driver, with the owner label
+// when owned.
+func newStorageClass(name string, owned bool) *storagev1.StorageClass {
+	sc := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: name},
+		Provisioner: "efs.csi.aws.com",
+	}
+	if owned {
+		sc.Labels = map[string]string{storageClassOwnerLabel: "true"}
+	}
+	return sc
+}
+
+// newManagedStorageClass returns the StorageClass applied by the operator with
+// the given parameters.
+func newManagedStorageClass(parameters map[string]string) *storagev1.StorageClass {
+	sc, err := getStorageClass(parameters)
+	if err != nil {
+		panic(err)
+	}
+	return sc
+}
+
+func TestStorageClassControllerSync(t *testing.T) {
+	config := newDriverConfigMap(map[string]string{fileSystemIDKey: "fs-0123456789abcdef0"})
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		// expected results
+		expectedErr            string
+		expectedStorageClasses []string
+	}{
+		{
+			name:                   "file system configured",
+			objects:                []runtime.Object{config},
+			expectedStorageClasses: []string{"efs-sc"},
+		},
+		{
+			name:                   "user StorageClass is not touched",
+			objects:                []runtime.Object{config, newStorageClass("efs-sc-user", false)},
+			expectedStorageClasses: []string{"efs-sc", "efs-sc-user"},
+		},
+		{
+			name:                   "unowned StorageClass with the managed name",
+			objects:                []runtime.Object{config, newStorageClass("efs-sc", false)},
+			expectedErr:            "StorageClass efs-sc already exists and is not managed by the operator",
+			expectedStorageClasses: []string{"efs-sc"},
+		},
+		{
+			name:    "file system removed from the ConfigMap",
+			objects: []runtime.Object{newDriverConfigMap(nil), newStorageClass("efs-sc", true)},
+		},
+		{
+			name:    "ConfigMap removed",
+			objects: []runtime.Object{newStorageClass("efs-sc", true)},
+		},
+		{
+			name:                   "unowned StorageClass is kept without a file system",
+			objects:                []runtime.Object{newStorageClass("efs-sc", false)},
+			expectedStorageClasses: []string{"efs-sc"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestStorageClassController(test.objects...)
+			err := c.syncErr()
+			if test.expectedErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), test.expectedErr)) {
+				t.Fatalf("expected error containing %q, got %v", test.expectedErr, err)
+			}
+			if scs := c.storageClasses(t); !reflect.DeepEqual(scs, test.expectedStorageClasses) {
+				t.Errorf("expected StorageClasses %v, got %v", test.expectedStorageClasses, scs)
+			}
+		})
+	}
+}
+
+func TestValidateStorageClassParameters(t *testing.T) {
+	tests := []struct {
+		name        string
+		parameters  map[string]string
+		expectedErr string
+	}{
+		{
+			name: "no parameters",
+		},
+		{
+			name: "valid parameters",
+			parameters: map[string]string{
+				fileSystemIDKey:   "fs-0123456789abcdef0",
+				basePathKey:       "/data",
+				directoryPermsKey: "0750",
+				gidRangeStartKey:  "1000",
+				gidRangeEndKey:    "2000",
+			},
+		},
+		{
+			name:        "missing file system",
+			parameters:  map[string]string{basePathKey: "/data"},
+			expectedErr: "StorageClass parameters [basePath] require fileSystemId",
+		},
+		{
+			name:        "incomplete gid range",
+			parameters:  map[string]string{fileSystemIDKey: "fs-0123456789abcdef0", gidRangeStartKey: "1000"},
+			expectedErr: "gidRangeStart and gidRangeEnd must be set together",
+		},
+		{
+			name:        "reversed gid range",
+			parameters:  map[string]string{fileSystemIDKey: "fs-0123456789abcdef0", gidRangeStartKey: "2000", gidRangeEndKey: "1000"},
+			expectedErr: "gidRangeStart 2000 is greater than gidRangeEnd 1000",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateStorageClassParameters(test.parameters)
+			if test.expectedErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if test.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), test.expectedErr)) {
+				t.Errorf("expected error containing %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateStorageClassParameter(t *testing.T) {
+	tests := []struct {
+		key         string
+		value       string
+		expectedErr string
+	}{
+		{key: fileSystemIDKey, value: "fs-0123456789abcdef0"},
+		{key: fileSystemIDKey, value: "fsap-0123456789abcdef0", expectedErr: "must be an EFS file system ID"},
+		{key: basePathKey, value: "/dynamic"},
+		{key: basePathKey, value: "dynamic", expectedErr: "must be an absolute path"},
+		{key: directoryPermsKey, value: "700"},
+		{key: directoryPermsKey, value: "0789", expectedErr: "must be an octal mode"},
+		{key: uidKey, value: "0"},
+		{key: gidKey, value: "-1", expectedErr: "must be a non-negative number"},
+		{key: gidRangeEndKey, value: "many", expectedErr: "must be a non-negative number"},
+	}
+	for _, test := range tests {
+		t.Run(test.key+"="+test.value, func(t *testing.T) {
+			err := validateStorageClassParameter(test.key, test.value)
+			if test.expectedErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if test.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), test.expectedErr)) {
+				t.Errorf("expected error containing %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}